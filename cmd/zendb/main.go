@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rnpridgeon/zendb"
+)
+
+const usage = `Usage: zendb [-config path] <command> [flags]
+
+Commands:
+  sync      export zendesk updates into the database, once or as a daemon
+  backfill  re-export a single resource from a given date
+  status    show the committed checkpoints
+  reset     discard the checkpoint for a resource so it is exported from scratch
+  migrate   create or upgrade the database schema, or roll it back to a version
+
+Run 'zendb <command> -h' for command flags.
+`
+
+type command func(ctx context.Context, p *zendb.Pipeline, args []string) error
+
+var commands = map[string]command{
+	"sync":     syncCmd,
+	"backfill": backfillCmd,
+	"status":   statusCmd,
+	"reset":    resetCmd,
+	"migrate":  migrateCmd,
+}
+
+func main() {
+	confPath := flag.String("config", "./exclude/conf.json", "path to the JSON configuration, see exampleConfig.json")
+	interval := flag.Duration("interval", zendb.HOUR, "time between syncs when running as a daemon")
+	timeout := flag.Duration("timeout", zendb.MINUTE, "time limit for each zendesk request")
+	replay := flag.String("replay", "", "import archived pages from this directory, or from the archive bucket with -replay "+zendb.ARCHIVE+", rather than from zendesk")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	run, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	conf, err := zendb.LoadConfig(*confPath)
+	maybeFatal(err)
+
+	client := &http.Client{Timeout: *timeout}
+	sink, err := conf.OpenSink()
+	maybeFatal(err)
+	var source zendb.Source
+	if *replay != "" {
+		source, err = conf.Replay(client, *replay, sink)
+	} else {
+		source, err = conf.OpenSource(client, sink)
+	}
+	maybeFatal(err)
+
+	pipeline := zendb.NewPipeline(source, sink, *interval)
+	conf.RegisterFieldTransformations(pipeline)
+	if _, ok := sink.(zendb.Execer); ok {
+		pipeline.RegisterPostProcessing(zendb.EnrichTickets)
+	}
+
+	err = run(interruptible(), pipeline, flag.Args()[1:])
+	// file sinks complete what they have written on close
+	if c, ok := sink.(io.Closer); ok {
+		if closeErr := c.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	maybeFatal(err)
+}
+
+func syncCmd(ctx context.Context, p *zendb.Pipeline, args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	once := flags.Bool("once", false, "sync a single time and exit, the default without -daemon")
+	daemon := flags.Bool("daemon", false, "keep syncing once per -interval until interrupted")
+	flags.Parse(args)
+
+	if *once && *daemon {
+		return fmt.Errorf("-once and -daemon are mutually exclusive")
+	}
+	if !*daemon {
+		return p.RunOnce(ctx)
+	}
+
+	if err := p.Run(ctx); !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+func backfillCmd(ctx context.Context, p *zendb.Pipeline, args []string) error {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	resource := flags.String("resource", "", "resource to backfill: "+strings.Join(zendb.Resources, ", "))
+	since := flags.String("since", "", "date to export from, YYYY-MM-DD")
+	flags.Parse(args)
+
+	start, err := time.Parse("2006-01-02", *since)
+	if err != nil {
+		return fmt.Errorf("invalid -since %q: %w", *since, err)
+	}
+	return p.Backfill(ctx, *resource, start)
+}
+
+func statusCmd(ctx context.Context, p *zendb.Pipeline, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	flags.Parse(args)
+
+	state, err := p.State(ctx)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(state))
+	for name := range state {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECKPOINT\tKIND\tVALUE\tWRITTEN\tRUN")
+	for _, name := range names {
+		c := state[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, c.Kind, c.Value, c.Written_at.UTC().Format(time.RFC3339), c.Run_id)
+	}
+	return w.Flush()
+}
+
+func resetCmd(ctx context.Context, p *zendb.Pipeline, args []string) error {
+	flags := flag.NewFlagSet("reset", flag.ExitOnError)
+	resource := flags.String("resource", "", "resource to reset: "+strings.Join(zendb.Resources, ", "))
+	flags.Parse(args)
+
+	return p.Reset(ctx, *resource)
+}
+
+func migrateCmd(ctx context.Context, p *zendb.Pipeline, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	to := flags.Int64("to", -1, "schema version to migrate up or down to, the latest by default")
+	show := flags.Bool("version", false, "print the current schema version and exit")
+	flags.Parse(args)
+
+	if *show {
+		version, err := p.SchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println(version)
+		return nil
+	}
+
+	version, err := p.Migrate(ctx, *to)
+	log.Printf("INFO: Schema is at version %d", version)
+	return err
+}
+
+// interruptible - context cancelled on SIGINT or SIGTERM
+func interruptible() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("INFO: Received %s, shutting down...", sig)
+		cancel()
+	}()
+	return ctx
+}
+
+func maybeFatal(err error) {
+	if err != nil {
+		log.Fatal("Fatal:", err)
+	}
+}
@@ -0,0 +1,216 @@
+package zendb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/rnpridgeon/zendb/provider/dump"
+	"github.com/rnpridgeon/zendb/provider/mysql"
+	"github.com/rnpridgeon/zendb/provider/parquet"
+	"github.com/rnpridgeon/zendb/provider/postgres"
+	"github.com/rnpridgeon/zendb/provider/s3"
+	"github.com/rnpridgeon/zendb/provider/sink"
+	"github.com/rnpridgeon/zendb/provider/sqlite"
+	"github.com/rnpridgeon/zendb/provider/zendesk"
+)
+
+// database types
+const (
+	MYSQL    = "mysql"
+	POSTGRES = "postgres"
+	SQLITE   = "sqlite"
+	PARQUET  = "parquet"
+	DUMP     = "dump"
+)
+
+// ARCHIVE - names the archive section's bucket as the location to replay from
+const ARCHIVE = "archive"
+
+// Config - see exampleConfig.json
+type Config struct {
+	ZDconf *zendesk.ZendeskConfig `json:"zendesk"`
+	DBconf *DatabaseConfig        `json:"database"`
+	Fields *FieldConfig           `json:"fields"`
+	// optional, archives every page fetched from zendesk
+	Archive *s3.S3Config `json:"archive"`
+}
+
+// DatabaseConfig - the database section, Type selects the sink which decodes the remaining settings
+type DatabaseConfig struct {
+	Type string `json:"type"`
+	raw  json.RawMessage
+}
+
+func (d *DatabaseConfig) UnmarshalJSON(raw []byte) error {
+	var section struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &section); err != nil {
+		return err
+	}
+
+	d.Type = section.Type
+	d.raw = append(d.raw[:0], raw...)
+	return nil
+}
+
+// Decode - unmarshals the section into the configuration of the selected sink
+func (d *DatabaseConfig) Decode(conf interface{}) error {
+	return json.Unmarshal(d.raw, conf)
+}
+
+// FieldConfig - ids of the ticket custom fields to normalize, zero disables the transformation
+type FieldConfig struct {
+	Component int64 `json:"component"`
+	Priority  int64 `json:"priority"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	cFile, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer cFile.Close()
+
+	var conf Config
+	if err = json.NewDecoder(cFile).Decode(&conf); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if conf.ZDconf == nil || conf.DBconf == nil {
+		return nil, fmt.Errorf("%s requires both zendesk and database sections", path)
+	}
+	return &conf, nil
+}
+
+// Open - connects to the source and sink described by the configuration
+func (c *Config) Open(client *http.Client) (Source, Sink, error) {
+	sink, err := c.OpenSink()
+	if err != nil {
+		return nil, nil, err
+	}
+	source, err := c.OpenSource(client, sink)
+	if err != nil {
+		return nil, nil, err
+	}
+	return source, sink, nil
+}
+
+// OpenSource - zendesk, archiving every page fetched to the archive section's bucket and to sink should it write
+// pages as received, e.g. the dump's NDJSON
+func (c *Config) OpenSource(client *http.Client, sink Sink) (Source, error) {
+	var archives zendesk.Archives
+	if c.Archive != nil {
+		bucket, err := s3.Open(client, c.Archive)
+		if err != nil {
+			return nil, err
+		}
+		archives = append(archives, bucket)
+	}
+	if raw, ok := sink.(zendesk.Archive); ok {
+		archives = append(archives, raw)
+	}
+
+	source := zendesk.Open(client, c.ZDconf)
+	switch len(archives) {
+	case 0:
+	case 1:
+		source.ArchiveTo(archives[0])
+	default:
+		source.ArchiveTo(archives)
+	}
+	return source, nil
+}
+
+// Replay - source replaying the pages archived in the archive section's bucket when from is ARCHIVE, and those
+// mirrored to the directory from otherwise. Pages are handed to sink as archived should it write pages as received.
+func (c *Config) Replay(client *http.Client, from string, sink Sink) (Source, error) {
+	var archive zendesk.ArchiveReader = zendesk.Directory(from)
+	if from == ARCHIVE {
+		if c.Archive == nil {
+			return nil, fmt.Errorf("replaying the %s requires an %s section", ARCHIVE, ARCHIVE)
+		}
+		bucket, err := s3.Open(client, c.Archive)
+		if err != nil {
+			return nil, err
+		}
+		archive = bucket
+	}
+
+	source := zendesk.Replay(archive)
+	if raw, ok := sink.(zendesk.Archive); ok {
+		source.ArchiveTo(raw)
+	}
+	return source, nil
+}
+
+// sinks - opens the sink of each database type from the database section
+var sinks = map[string]func(d *DatabaseConfig) (Sink, error){
+	MYSQL: func(d *DatabaseConfig) (Sink, error) {
+		var conf mysql.MysqlConfig
+		if err := d.Decode(&conf); err != nil {
+			return nil, err
+		}
+		return opened(mysql.Open(&conf))
+	},
+	POSTGRES: func(d *DatabaseConfig) (Sink, error) {
+		var conf postgres.PostgresConfig
+		if err := d.Decode(&conf); err != nil {
+			return nil, err
+		}
+		return opened(postgres.Open(&conf))
+	},
+	SQLITE: func(d *DatabaseConfig) (Sink, error) {
+		var conf sqlite.SqliteConfig
+		if err := d.Decode(&conf); err != nil {
+			return nil, err
+		}
+		return opened(sqlite.Open(&conf))
+	},
+	PARQUET: func(d *DatabaseConfig) (Sink, error) {
+		var conf parquet.ParquetConfig
+		if err := d.Decode(&conf); err != nil {
+			return nil, err
+		}
+		return opened(parquet.Open(&conf))
+	},
+	DUMP: func(d *DatabaseConfig) (Sink, error) {
+		var conf dump.DumpConfig
+		if err := d.Decode(&conf); err != nil {
+			return nil, err
+		}
+		return opened(dump.Open(&conf))
+	},
+}
+
+// opened - s unless opening it failed, a nil provider is not returned as a non-nil Sink
+func opened(s Sink, err error) (Sink, error) {
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenSink - the sink selected by database.type
+func (c *Config) OpenSink() (Sink, error) {
+	open, ok := sinks[c.DBconf.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database type %q", c.DBconf.Type)
+	}
+	return open(c.DBconf)
+}
+
+// RegisterFieldTransformations - registers the custom field transformations enabled in the configuration
+func (c *Config) RegisterFieldTransformations(p *Pipeline) {
+	if c.Fields == nil {
+		return
+	}
+	if c.Fields.Component != 0 {
+		p.RegisterTransformation(sink.TICKET_FIELD_VALUES, ComponentTransformation(c.Fields.Component))
+	}
+	if c.Fields.Priority != 0 {
+		p.RegisterTransformation(sink.TICKET_FIELD_VALUES, PriorityTransformation(c.Fields.Priority))
+	}
+}
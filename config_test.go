@@ -0,0 +1,60 @@
+package zendb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/rnpridgeon/zendb/provider/postgres"
+	"github.com/rnpridgeon/zendb/provider/sqlite"
+)
+
+func TestConfigSelectsSinkByType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conf.json")
+	conf := `{"zendesk": {"subdomain": "company"}, "database": {"type": "postgres", "hostname": "db", "sslmode": "require"}}`
+	if err := ioutil.WriteFile(path, []byte(conf), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, sink, err := c.Open(http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sink.(*postgres.PostgresProvider); !ok {
+		t.Errorf("expected a postgres sink, got %T", sink)
+	}
+
+	c.DBconf = &DatabaseConfig{}
+	path = filepath.Join(t.TempDir(), "zendb.db")
+	if err = c.DBconf.UnmarshalJSON([]byte(`{"type": "sqlite", "path": "` + path + `"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, sink, err = c.Open(http.DefaultClient); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sink.(*sqlite.SqliteProvider); !ok {
+		t.Errorf("expected a sqlite sink, got %T", sink)
+	}
+
+	c.DBconf.Type = "oracle"
+	if _, _, err = c.Open(http.DefaultClient); err == nil {
+		t.Error("expected an unsupported database type to fail")
+	}
+}
+
+func TestLoadConfigRequiresDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conf.json")
+	if err := ioutil.WriteFile(path, []byte(`{"zendesk": {}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("expected a configuration without a database section to fail")
+	}
+}
@@ -1,175 +1,170 @@
 package zendb
 
 import (
-	"encoding/json"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"github.com/rnpridgeon/zendb/models"
+	"github.com/rnpridgeon/zendb/provider/dump"
 	"github.com/rnpridgeon/zendb/provider/mysql"
+	"github.com/rnpridgeon/zendb/provider/parquet"
+	"github.com/rnpridgeon/zendb/provider/sink"
+	"github.com/rnpridgeon/zendb/provider/sqlite"
 	"github.com/rnpridgeon/zendb/provider/zendesk"
-	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
-	"github.com/rnpridgeon/zendb/models"
-	"strings"
 )
 
-// TODO: make provider interface
+// TODO: build a field cache and look these up by title
+const (
+	componentField int64 = 33020448
+	priorityField  int64 = 33471847
+)
+
+// Ensure providers satisfy the pipeline interfaces
 var (
-	conf Config
-	sink *mysql.MysqlProvider
-	source *zendesk.ZDProvider
-	requireMetrics []int64
+	_ Source   = (*zendesk.ZDProvider)(nil)
+	_ Source   = (*zendesk.ReplayProvider)(nil)
+	_ Sink     = (*mysql.MysqlProvider)(nil)
+	_ Execer   = (*mysql.MysqlProvider)(nil)
+	_ Migrator = (*mysql.MysqlProvider)(nil)
+	_ Sink     = (*sqlite.SqliteProvider)(nil)
+	_ Execer   = (*sqlite.SqliteProvider)(nil)
+	_ Dialect  = (*sqlite.SqliteProvider)(nil)
+	_ Migrator = (*sqlite.SqliteProvider)(nil)
+	_ Sink     = (*parquet.ParquetProvider)(nil)
+	_ Resetter = (*parquet.ParquetProvider)(nil)
+	_ Sink     = (*dump.DumpProvider)(nil)
+	_ Resetter = (*dump.DumpProvider)(nil)
 )
 
-type Config struct {
-	ZDconf *zendesk.ZendeskConfig `json:"zendesk"`
-	DBconf *mysql.MysqlConfig     `json:"database"`
-}
+func TestScheduled(t *testing.T) {
+	source, db := open(t)
 
-const (
-	insertPriority =`
-	UPDATE tickets
-			JOIN ticket_metadata on tickets.id = ticket_metadata.ticket_id
-			JOIN ticket_fields on field_id = ticket_fields.id
-		SET tickets.priority = ticket_metadata.transformed_value
-		WHERE ticket_fields.title = "Case Priority"`
-
-	insertComponent =`
-		UPDATE tickets
-			JOIN ticket_metadata on tickets.id = ticket_metadata.ticket_id
-			JOIN ticket_fields on field_id = ticket_fields.id
-		SET tickets.component = ticket_metadata.transformed_value
-		WHERE ticket_fields.title = "Component"`
-
-	insertVersion =`
-		UPDATE tickets
-			JOIN ticket_metadata on tickets.id = ticket_metadata.ticket_id
-			JOIN ticket_fields on field_id = ticket_fields.id
-		SET tickets.version = ticket_metadata.raw_value
-		WHERE ticket_fields.title like "%Kafka Version"`
-
-	insertTTFR = `
-		UPDATE tickets
-			JOIN ticket_metrics on tickets.id = ticket_metrics.ticket_id
-		SET tickets.ttfr = ticket_metrics.ttfr`
-
-	insertSolved = `
-		UPDATE tickets
-			JOIN ticket_metrics on tickets.id = ticket_metrics.ticket_id
-		SET tickets.solved_at = ticket_metrics.solved_at`
-)
+	pipeline := NewPipeline(source, db, 1*MINUTE)
+	pipeline.RegisterTransformation(sink.TICKET_FIELD_VALUES, ComponentTransformation(componentField))
+	pipeline.RegisterTransformation(sink.TICKET_FIELD_VALUES, PriorityTransformation(priorityField))
+	pipeline.RegisterPostProcessing(EnrichTickets)
 
-func transformComponent(obj interface{}) {
-	entity := obj.(*models.Custom_fields)
-	//TODO: Component, build cache pull from there
-	var component int64 = 33020448
-	if entity.Id == component && entity.Value != nil {
-		val := entity.Value.(string)
-		switch {
-		case strings.Contains(val, "c3") || strings.Contains(val, "confluent_control_center"):
-			entity.Transformed = "c3"
-		case strings.Contains(val, "broker"):
-			entity.Transformed="broker"
-		case strings.Contains(val, "auto_data_balancer"):
-			entity.Transformed = "adb"
-		case strings.Contains(val, "_jms_"):
-			entity.Transformed = "clients-jms"
-		case strings.Contains(val, "python_"):
-			entity.Transformed = "clients-python"
-		case strings.Contains(val, "client_net"):
-			entity.Transformed = "clients-dotNET"
-		case strings.Contains(val, "_c_"):
-			entity.Transformed = "clients-c/c++"
-		case strings.Contains(val, "_go_"):
-			entity.Transformed = "clients-golang"
-		case strings.Contains(val, "third-party"):
-			entity.Transformed = "clients-third-party"
-		case strings.Contains(val, "java_"):
-			entity.Transformed = "clients-java"
-		case strings.Contains(val, "java_"):
-			entity.Transformed = "clients-java"
-		default:
-			entity.Transformed = entity.Value.(string)
-		}
+	// Kill scheduler
+	ctx, cancel := context.WithTimeout(context.Background(), 1*MINUTE)
+	defer cancel()
+
+	if _, err := pipeline.Migrate(ctx, -1); err != nil {
+		t.Fatal(err)
 	}
-}
 
-func transformPriority(obj interface{}) {
-	entity := obj.(*models.Custom_fields)
-	// TODO: Case Priority , build cache, pull from there
-	var priority int64 = 33471847
-	if entity.Id == priority && entity.Value != nil {
-		if len(entity.Value.(string)) >=2 {
-			entity.Transformed = entity.Value.(string)[:2]
-		}
+	if err := pipeline.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatal(err)
 	}
 }
 
-func buildMetricsList(obj interface{}) {
-	requireMetrics = append(requireMetrics, obj.(*models.Ticket).Id)
-}
+// TestPipelineAgainstSqlite - a full run, post processing included, against a database file instead of a server
+func TestPipelineAgainstSqlite(t *testing.T) {
+	sink, err := sqlite.Open(&sqlite.SqliteConfig{Path: filepath.Join(t.TempDir(), "zendb.db")})
+	maybeFatal(t, err)
+	defer sink.Close()
+
+	// fakeSource lists a single custom field, 1, titled Component
+	updated := time.Now()
+	source := &fakeSource{
+		users: []models.User{{Id: 7, Name: "jane"}},
+		tickets: []models.Ticket{
+			{Id: 1, Status: "open", Requester_id: 7, Submitter_id: 7, Assignee_id: 7, Updated_at: updated,
+				Custom_fields: []models.Custom_fields{{Id: 1, Value: "kafka_broker"}}},
+			{Id: 2, Status: "solved", Requester_id: 7, Submitter_id: 7, Assignee_id: 7, Updated_at: updated},
+		},
+	}
 
-// Test custom query/post processing
-func PostProcessing() {
-	defer TimeTrack(time.Now(), "Ticket post processing")
+	pipeline := NewPipeline(source, sink, MINUTE)
+	pipeline.RegisterTransformation(sqlite.TICKET_FIELD_VALUES, ComponentTransformation(1))
+	pipeline.RegisterPostProcessing(EnrichTickets)
 
-	sink.ExecRaw(insertPriority)
-	sink.ExecRaw(insertComponent)
-	sink.ExecRaw(insertVersion)
-	sink.ExecRaw(insertSolved)
-	sink.ExecRaw(insertTTFR)
-}
+	ctx := context.Background()
+	_, err = pipeline.Migrate(ctx, -1)
+	maybeFatal(t, err)
+	maybeFatal(t, pipeline.RunOnce(ctx))
 
-func TestScheduled(t *testing.T) {
-	InitialLoad()
+	tickets, err := sink.ExportTickets(ctx, 0, 0)
+	maybeFatal(t, err)
+	if len(tickets) != 2 {
+		t.Fatalf("expected 2 tickets, got %d", len(tickets))
+	}
+	for _, e := range tickets {
+		if e.Id == 1 && e.Component != "broker" {
+			t.Errorf("expected post processing to copy the transformed component onto ticket 1, got %q", e.Component)
+		}
+	}
 
-	scheduler := NewScheduler(1 * MINUTE, Process)
-	// Kill scheduler
-	go func() {
-		time.Sleep( 1 * MINUTE)
-		scheduler.Stop()
-	}()
-	scheduler.Start()
+	state, err := pipeline.State(ctx)
+	maybeFatal(t, err)
+	if state[TICKET_EXPORT].Cursor() != "30" {
+		t.Errorf("unexpected ticket export checkpoint %v", state[TICKET_EXPORT])
+	}
 }
 
-func InitialLoad() {
-	sink.RegisterTransformation("ticket_metadata", transformComponent)
-	sink.RegisterTransformation("ticket_metadata", transformPriority)
-	sink.RegisterTransformation("tickets", buildMetricsList)
+func TestPipelineReplaysArchive(t *testing.T) {
+	sink, err := sqlite.Open(&sqlite.SqliteConfig{Path: filepath.Join(t.TempDir(), "zendb.db")})
+	maybeFatal(t, err)
+	defer sink.Close()
+
+	archive := zendesk.Directory(t.TempDir())
+	for key, page := range map[string]string{
+		"ticket_fields/20200301T120000.000000000Z/000001.json.gz": `{"ticket_fields": [{"id": 1, "title": "Component"}]}`,
+		"tickets/20200301T120000.000000000Z/000001_c1.json.gz": `{"tickets": [{"id": 1, "status": "open", "requester_id": 7,
+			"submitter_id": 7, "assignee_id": 7, "updated_at": "2020-03-01T12:00:00Z", "custom_fields": [{"id": 1, "value": "kafka_broker"}]}],
+			"users": [{"id": 7, "name": "jane"}], "after_cursor": "c1"}`,
+	} {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(page))
+		zw.Close()
+		maybeFatal(t, archive.Put(context.Background(), key, buf.Bytes()))
+	}
 
-	source.ListTicketFields(sink.ImportTicketFields)
-	source.ListGroups(sink.ImportGroups)
-	Process()
-}
+	pipeline := NewPipeline(zendesk.Replay(archive), sink, MINUTE)
+	pipeline.RegisterTransformation(sqlite.TICKET_FIELD_VALUES, ComponentTransformation(1))
+	pipeline.RegisterPostProcessing(EnrichTickets)
+
+	ctx := context.Background()
+	_, err = pipeline.Migrate(ctx, -1)
+	maybeFatal(t, err)
+	// the second run finds nothing after c1
+	maybeFatal(t, pipeline.RunOnce(ctx))
+	maybeFatal(t, pipeline.RunOnce(ctx))
+
+	tickets, err := sink.ExportTickets(ctx, 0, 0)
+	maybeFatal(t, err)
+	if len(tickets) != 1 || tickets[0].Component != "broker" {
+		t.Errorf("expected the archived ticket to be transformed, got %v", tickets)
+	}
 
-func Process() {
-	start := sink.FetchState()
-	log.Printf("%+v\n", start)
-	log.Printf("INFO: Fetching organization updates %v...\n", time.Unix(start["organization_export"],0))
-	sink.CommitSequence("organization_export", source.ExportOrganizations(start["organization_export"], sink.ImportOrganizations))
-	log.Printf("INFO: Fetching User updates since %v...\n",time.Unix(start["user_export"],0) )
-	sink.CommitSequence("user_export", source.ExportUsers(start["user_export"], sink.ImportUsers))
-	log.Printf("INFO: Fetching ticket updates since %v...\n", time.Unix(start["ticket_export"],0))
-	sink.CommitSequence("ticket_export", source.ExportTickets(start["ticket_export"], sink.ImportTickets))
-	log.Printf("INFO: Fetching ticket metric updates since ticket id %d...\n", start["ticket_metrics"])
-	//source.ExportTicketMetrics(requireMetrics , sink.ImportTicketMetrics)
-	log.Printf("INFO: Fetching ticket audits since audit id %d", start["ticket_audit"])
-	source.ExportTicketAudits(start["ticket_audit"], sink.ImportAudit)
-	PostProcessing()
+	state, err := pipeline.State(ctx)
+	maybeFatal(t, err)
+	if state[TICKET_EXPORT].Cursor() != "c1" {
+		t.Errorf("unexpected ticket export checkpoint %v", state[TICKET_EXPORT])
+	}
 }
 
-func init() {
-	cFile, err := os.Open("./exclude/conf.json")
-	maybeFatal(err)
+// open - connects to the providers described in ./exclude/conf.json, written by util/setup.sh
+func open(t *testing.T) (Source, Sink) {
+	conf, err := LoadConfig("./exclude/conf.json")
+	if os.IsNotExist(err) {
+		t.Skip("./exclude/conf.json not found, run util/setup.sh to configure integration tests")
+	}
+	maybeFatal(t, err)
 
-	maybeFatal(json.NewDecoder(cFile).Decode(&conf))
+	source, sink, err := conf.Open(http.DefaultClient)
+	maybeFatal(t, err)
 
-	sink = mysql.Open(conf.DBconf)
-	source = zendesk.Open(http.DefaultClient, conf.ZDconf)
+	return source, sink
 }
 
-func maybeFatal(err error) {
+func maybeFatal(t *testing.T, err error) {
 	if err != nil {
-		log.Fatal("Fatal:", err)
+		t.Fatal("Fatal:", err)
 	}
 }
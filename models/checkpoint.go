@@ -0,0 +1,100 @@
+package models
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// checkpoint kinds, each determines how Value is encoded
+const (
+	INT_CHECKPOINT       = "int"
+	TIMESTAMP_CHECKPOINT = "timestamp"
+	CURSOR_CHECKPOINT    = "cursor"
+	JSON_CHECKPOINT      = "json"
+)
+
+// Notes: resource type: Metadata, persisted by sinks between runs
+// checkpoint - where an export resumes from along with when, and by which run, it was written
+type Checkpoint struct {
+	Name       string    `json:"name"`
+	Kind       string    `json:"kind"`
+	Value      string    `json:"value"`
+	Written_at time.Time `json:"written_at"`
+	Run_id     string    `json:"run_id"`
+}
+
+// IntCheckpoint - checkpoint holding an id or counter
+func IntCheckpoint(name string, val int64) Checkpoint {
+	return Checkpoint{Name: name, Kind: INT_CHECKPOINT, Value: strconv.FormatInt(val, 10)}
+}
+
+// TimestampCheckpoint - checkpoint holding a point in time, stored as RFC3339
+func TimestampCheckpoint(name string, at time.Time) Checkpoint {
+	return Checkpoint{Name: name, Kind: TIMESTAMP_CHECKPOINT, Value: at.UTC().Format(time.RFC3339)}
+}
+
+// CursorCheckpoint - checkpoint holding an opaque cursor handed out by zendesk
+func CursorCheckpoint(name string, cursor string) Checkpoint {
+	return Checkpoint{Name: name, Kind: CURSOR_CHECKPOINT, Value: cursor}
+}
+
+// JSONCheckpoint - checkpoint holding any state which marshals to JSON
+func JSONCheckpoint(name string, val interface{}) (Checkpoint, error) {
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return Checkpoint{Name: name, Kind: JSON_CHECKPOINT, Value: string(raw)}, nil
+}
+
+// Int - the value of an int checkpoint, zero for any other kind
+func (c Checkpoint) Int() int64 {
+	if c.Kind != INT_CHECKPOINT {
+		return 0
+	}
+	val, _ := strconv.ParseInt(c.Value, 10, 64)
+	return val
+}
+
+// Time - the value of a timestamp checkpoint, the zero time for any other kind
+func (c Checkpoint) Time() time.Time {
+	if c.Kind != TIMESTAMP_CHECKPOINT {
+		return time.Time{}
+	}
+	at, _ := time.Parse(time.RFC3339, c.Value)
+	return at
+}
+
+// Cursor - the value of a cursor checkpoint, empty for any other kind
+func (c Checkpoint) Cursor() string {
+	if c.Kind != CURSOR_CHECKPOINT {
+		return ""
+	}
+	return c.Value
+}
+
+// Advances - whether c may replace prev, ints and timestamps never move backwards while opaque cursors and
+// JSON always replace what came before. A change of kind, e.g. a timestamp superseded by a cursor, always advances.
+func (c Checkpoint) Advances(prev Checkpoint) bool {
+	if c.Kind != prev.Kind {
+		return true
+	}
+
+	switch c.Kind {
+	case INT_CHECKPOINT:
+		return c.Int() >= prev.Int()
+	case TIMESTAMP_CHECKPOINT:
+		return !c.Time().Before(prev.Time())
+	default:
+		return true
+	}
+}
+
+// Decode - unmarshals the value of a JSON checkpoint into val, leaving val untouched for any other kind
+func (c Checkpoint) Decode(val interface{}) error {
+	if c.Kind != JSON_CHECKPOINT {
+		return nil
+	}
+	return json.Unmarshal([]byte(c.Value), val)
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if c := IntCheckpoint("users", 42); c.Int() != 42 || c.Cursor() != "" {
+		t.Errorf("unexpected int checkpoint %+v", c)
+	}
+	if c := TimestampCheckpoint("organization_export", at); !c.Time().Equal(at) || c.Int() != 0 {
+		t.Errorf("unexpected timestamp checkpoint %+v", c)
+	}
+	if c := CursorCheckpoint("ticket_export", "abc"); c.Cursor() != "abc" || !c.Time().IsZero() {
+		t.Errorf("unexpected cursor checkpoint %+v", c)
+	}
+
+	c, err := JSONCheckpoint("ticket_audit", map[string]int64{"id": 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var val map[string]int64
+	if err = c.Decode(&val); err != nil || val["id"] != 7 {
+		t.Errorf("expected id 7, got %v: %v", val, err)
+	}
+}
+
+func TestCheckpointAdvances(t *testing.T) {
+	at := time.Unix(1000, 0)
+
+	cases := []struct {
+		name     string
+		next     Checkpoint
+		prev     Checkpoint
+		advances bool
+	}{
+		{"larger id", IntCheckpoint("a", 5), IntCheckpoint("a", 4), true},
+		{"same id", IntCheckpoint("a", 5), IntCheckpoint("a", 5), true},
+		{"smaller id", IntCheckpoint("a", 3), IntCheckpoint("a", 4), false},
+		{"later time", TimestampCheckpoint("a", at.Add(time.Second)), TimestampCheckpoint("a", at), true},
+		{"earlier time", TimestampCheckpoint("a", at.Add(-time.Second)), TimestampCheckpoint("a", at), false},
+		{"cursor", CursorCheckpoint("a", "older"), CursorCheckpoint("a", "newer"), true},
+		{"change of kind", CursorCheckpoint("a", "c"), TimestampCheckpoint("a", at), true},
+	}
+
+	for _, c := range cases {
+		if got := c.next.Advances(c.prev); got != c.advances {
+			t.Errorf("%s: expected %v, got %v", c.name, c.advances, got)
+		}
+	}
+}
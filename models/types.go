@@ -25,6 +25,18 @@ type Ticket struct {
 	Updated_at          time.Time           `json:"updated_at"`
 }
 
+// Doc: https://developer.zendesk.com/rest_api/docs/core/incremental_export#sideloading
+// Parent: root
+// Notes: resource type: Data, one page of the incremental ticket export and the records it sideloads
+// ticket_page - tickets along with their metrics, users, groups and organizations
+type Ticket_page struct {
+	Tickets       []Ticket         `json:"tickets"`
+	Metric_sets   []Ticket_metrics `json:"metric_sets"`
+	Users         []User           `json:"users"`
+	Groups        []Group          `json:"groups"`
+	Organizations []Organization   `json:"organizations"`
+}
+
 type Ticket_Enhanced struct {
 	Ticket
 	Version	string 				`json:"version"`
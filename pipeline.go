@@ -0,0 +1,309 @@
+package zendb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+// incrementally exported resources
+const (
+	ORGANIZATIONS = "organizations"
+	USERS         = "users"
+	TICKETS       = "tickets"
+	AUDITS        = "audits"
+)
+
+// checkpoints used to track incremental export progress
+const (
+	ORGANIZATION_EXPORT = "organization_export"
+	USER_EXPORT         = "user_export"
+	TICKET_EXPORT       = "ticket_export"
+	TICKET_AUDIT        = "ticket_audit"
+)
+
+// Resources - incrementally exported resources in the order they are synced, parents first
+var Resources = []string{ORGANIZATIONS, USERS, TICKETS, AUDITS}
+
+// Checkpoints - maps each resource to the checkpoint tracking its progress
+var Checkpoints = map[string]string{
+	ORGANIZATIONS: ORGANIZATION_EXPORT,
+	USERS:         USER_EXPORT,
+	TICKETS:       TICKET_EXPORT,
+	AUDITS:        TICKET_AUDIT,
+}
+
+// auditPosition - audits resume by id, the cursor zendesk handed out alongside is kept for reference
+type auditPosition struct {
+	Id     int64  `json:"id"`
+	Cursor string `json:"cursor"`
+}
+
+// Pipeline - moves zendesk resources from a Source into a Sink, either once or on a schedule
+type Pipeline struct {
+	source         Source
+	sink           Sink
+	interval       time.Duration
+	postProcessing []func(context.Context, Sink) error
+}
+
+func NewPipeline(source Source, sink Sink, interval time.Duration) *Pipeline {
+	return &Pipeline{
+		source:   source,
+		sink:     sink,
+		interval: interval,
+	}
+}
+
+// RegisterTransformation - applies fn to every entity written to target before it is persisted
+func (p *Pipeline) RegisterTransformation(target string, fn func(interface{})) {
+	p.sink.RegisterTransformation(target, fn)
+}
+
+// RegisterPostProcessing - runs fn against the sink after every successful sync, in registration order
+func (p *Pipeline) RegisterPostProcessing(fn func(context.Context, Sink) error) {
+	p.postProcessing = append(p.postProcessing, fn)
+}
+
+// RunOnce - refreshes metadata, exports everything updated since the last committed checkpoints and post processes
+func (p *Pipeline) RunOnce(ctx context.Context) error {
+	defer TimeTrack(time.Now(), "Sync")
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := p.refreshMetadata(ctx); err != nil {
+		return err
+	}
+
+	checkpoints, err := p.sink.FetchCheckpoints(ctx)
+	if err != nil {
+		return err
+	}
+
+	run := newRunID()
+	for _, resource := range Resources {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := p.export(ctx, run, resource, checkpoints[Checkpoints[resource]]); err != nil {
+			return err
+		}
+	}
+
+	for _, fn := range p.postProcessing {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(ctx, p.sink); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Backfill - re-exports a single time based resource from since onwards, regardless of its committed checkpoint
+func (p *Pipeline) Backfill(ctx context.Context, resource string, since time.Time) error {
+	if resource == AUDITS {
+		return fmt.Errorf("%s are exported by id and cannot be backfilled by time", AUDITS)
+	}
+	name, ok := Checkpoints[resource]
+	if !ok {
+		return fmt.Errorf("unknown resource %q", resource)
+	}
+
+	// Import fails on unknown ticket fields and groups, make sure they are current
+	if err := p.refreshMetadata(ctx); err != nil {
+		return err
+	}
+
+	return p.export(ctx, newRunID(), resource, models.TimestampCheckpoint(name, since))
+}
+
+// Reset - discards the checkpoint tracking resource so the next run exports it from scratch
+func (p *Pipeline) Reset(ctx context.Context, resource string) error {
+	name, ok := Checkpoints[resource]
+	if !ok {
+		return fmt.Errorf("unknown resource %q", resource)
+	}
+
+	db, ok := p.sink.(Resetter)
+	if !ok {
+		return fmt.Errorf("%T does not support resetting checkpoints", p.sink)
+	}
+
+	return db.ResetCheckpoint(ctx, name)
+}
+
+// Migrate - brings the sink's schema to version, a negative version applies every pending migration.
+// Returns the version the schema was left at, which on failure is the last migration that succeeded.
+func (p *Pipeline) Migrate(ctx context.Context, version int64) (int64, error) {
+	db, ok := p.sink.(Migrator)
+	if !ok {
+		return 0, fmt.Errorf("%T does not support schema migrations", p.sink)
+	}
+
+	return db.Migrate(ctx, version)
+}
+
+// SchemaVersion - version of the last migration applied to the sink
+func (p *Pipeline) SchemaVersion(ctx context.Context) (int64, error) {
+	db, ok := p.sink.(Migrator)
+	if !ok {
+		return 0, fmt.Errorf("%T does not support schema migrations", p.sink)
+	}
+
+	return db.SchemaVersion(ctx)
+}
+
+// State - the checkpoints currently committed to the sink
+func (p *Pipeline) State(ctx context.Context) (map[string]models.Checkpoint, error) {
+	return p.sink.FetchCheckpoints(ctx)
+}
+
+func (p *Pipeline) refreshMetadata(ctx context.Context) error {
+	log.Print("INFO: Fetching ticket fields...")
+	_, err := p.source.ListTicketFields(ctx, func(page []models.Ticket_field) error {
+		return p.sink.ImportTicketFields(ctx, page)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Print("INFO: Fetching groups...")
+	_, err = p.source.ListGroups(ctx, func(page []models.Group) error {
+		return p.sink.ImportGroups(ctx, page)
+	})
+	return err
+}
+
+// export - each page is imported atomically with the checkpoint resuming after it, so an interrupted export
+// resumes after its last persisted page. Cursor based exports start from the time or id recorded before their
+// first cursor was committed.
+func (p *Pipeline) export(ctx context.Context, run string, resource string, from models.Checkpoint) (err error) {
+	name := Checkpoints[resource]
+	since := sinceOf(from)
+
+	switch resource {
+	case ORGANIZATIONS:
+		log.Printf("INFO: Fetching organization updates since %v...", time.Unix(since, 0))
+		_, err = p.source.ExportOrganizations(ctx, since, func(page []models.Organization, next int64) error {
+			return p.sink.ImportOrganizations(ctx, page, stamp(models.TimestampCheckpoint(name, time.Unix(next, 0)), run))
+		})
+	case USERS:
+		logCursor("user", since, from.Cursor())
+		_, err = p.source.ExportUsers(ctx, since, from.Cursor(), func(page []models.User, next string) error {
+			return p.sink.ImportUsers(ctx, page, stamp(models.CursorCheckpoint(name, next), run))
+		})
+	case TICKETS:
+		logCursor("ticket", since, from.Cursor())
+		_, err = p.source.ExportTickets(ctx, since, from.Cursor(), func(page models.Ticket_page, next string) error {
+			return p.sink.ImportTickets(ctx, page, stamp(models.CursorCheckpoint(name, next), run))
+		})
+	case AUDITS:
+		err = p.exportAudits(ctx, run, from)
+	}
+	return err
+}
+
+// exportAudits - audits are listed newest first, their checkpoint only advances once every page down to the
+// last audit already seen is persisted
+func (p *Pipeline) exportAudits(ctx context.Context, run string, from models.Checkpoint) error {
+	name := Checkpoints[AUDITS]
+	position := auditPosition{Id: sinceOf(from)}
+	if err := from.Decode(&position); err != nil {
+		return fmt.Errorf("corrupt %s checkpoint %q: %w", name, from.Value, err)
+	}
+
+	log.Printf("INFO: Fetching ticket audits since audit id %d...", position.Id)
+	latest := position.Id
+	cursor, err := p.source.ExportTicketAudits(ctx, position.Id, func(page []models.Audit) error {
+		for _, e := range page {
+			if e.Id > latest {
+				latest = e.Id
+			}
+		}
+		return p.sink.ImportAudit(ctx, page)
+	})
+	if err != nil {
+		return err
+	}
+
+	next, err := models.JSONCheckpoint(name, auditPosition{Id: latest, Cursor: cursor})
+	if err != nil {
+		return err
+	}
+	return p.sink.CommitCheckpoint(ctx, stamp(next, run))
+}
+
+// stamp - attributes checkpoint to run
+func stamp(checkpoint models.Checkpoint, run string) models.Checkpoint {
+	checkpoint.Run_id = run
+	return checkpoint
+}
+
+// sinceOf - the unix time or id an export starts from, checkpoints written by earlier versions hold plain integers
+func sinceOf(from models.Checkpoint) int64 {
+	switch from.Kind {
+	case models.TIMESTAMP_CHECKPOINT:
+		return from.Time().Unix()
+	case models.INT_CHECKPOINT:
+		return from.Int()
+	default:
+		return 0
+	}
+}
+
+func logCursor(name string, since int64, cursor string) {
+	if cursor != "" {
+		log.Printf("INFO: Fetching %s updates from cursor %s...", name, cursor)
+		return
+	}
+	log.Printf("INFO: Fetching %s updates since %v...", name, time.Unix(since, 0))
+}
+
+// newRunID - identifies the checkpoints written by a single sync
+func newRunID() string {
+	return time.Now().UTC().Format("20060102T150405.000000000Z")
+}
+
+// Run - syncs immediately and then once per interval until ctx is cancelled or a sync fails permanently
+// Temporary failures, e.g. network errors or rate limiting, are logged and retried on the next interval.
+func (p *Pipeline) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var err error
+	scheduler := NewScheduler(p.interval, func(ctx context.Context) {
+		if err != nil {
+			return
+		}
+
+		err = p.RunOnce(ctx)
+		if isTemporary(err) && ctx.Err() == nil {
+			log.Printf("WARN: Sync failed, retrying in %s: %s", p.interval, err)
+			err = nil
+		}
+		if err != nil {
+			cancel()
+		}
+	})
+	scheduler.Start(ctx)
+
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// isTemporary - errors which are expected to succeed when retried, wherever they are in err's chain
+func isTemporary(err error) bool {
+	var temporary interface {
+		Temporary() bool
+	}
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
@@ -0,0 +1,308 @@
+package zendb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+type fakeSource struct {
+	// exported one page per ticket, each sideloading users
+	tickets []models.Ticket
+	users   []models.User
+	// returned by ExportTickets instead of its last page while non-zero, decremented on every call
+	failures  int
+	ticketErr error
+	// audits listed newest first along with the id they were requested since
+	audits      []models.Audit
+	auditsSince int64
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "temporary failure" }
+func (temporaryError) Temporary() bool { return true }
+
+func (s *fakeSource) ListTicketFields(ctx context.Context, process func([]models.Ticket_field) error) (int64, error) {
+	return 1, process([]models.Ticket_field{{Id: 1, Title: "Component"}})
+}
+
+func (s *fakeSource) ListGroups(ctx context.Context, process func([]models.Group) error) (int64, error) {
+	return 1, process([]models.Group{{Id: 1, Name: "support"}})
+}
+
+func (s *fakeSource) ExportOrganizations(ctx context.Context, since int64, process func([]models.Organization, int64) error) (int64, error) {
+	return since + 10, process(nil, since+10)
+}
+
+// advance - fake cursors count how far an export got, starting from since
+func advance(since int64, cursor string, n int64) string {
+	if cursor != "" {
+		since, _ = strconv.ParseInt(cursor, 10, 64)
+	}
+	return strconv.FormatInt(since+n, 10)
+}
+
+func (s *fakeSource) ExportUsers(ctx context.Context, since int64, cursor string, process func([]models.User, string) error) (string, error) {
+	next := advance(since, cursor, 20)
+	return next, process(nil, next)
+}
+
+// ExportTickets - every page advances the cursor by 15, an export without tickets still returns an empty page
+func (s *fakeSource) ExportTickets(ctx context.Context, since int64, cursor string, process func(models.Ticket_page, string) error) (string, error) {
+	pages := []models.Ticket_page{{}}
+	if len(s.tickets) > 0 {
+		pages = nil
+		for _, e := range s.tickets {
+			pages = append(pages, models.Ticket_page{
+				Tickets:     []models.Ticket{e},
+				Metric_sets: []models.Ticket_metrics{{Id: e.Id, Ticket_id: e.Id}},
+				Users:       s.users,
+			})
+		}
+	}
+
+	last := cursor
+	for i, page := range pages {
+		if s.failures > 0 && i == len(pages)-1 {
+			s.failures--
+			return last, s.ticketErr
+		}
+
+		next := advance(since, cursor, int64(15*(i+1)))
+		if err := process(page, next); err != nil {
+			return last, err
+		}
+		last = next
+	}
+	return last, nil
+}
+
+func (s *fakeSource) ExportTicketMetrics(ctx context.Context, tickets []int64, process func([]models.Ticket_metrics) error) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeSource) ExportTicketAudits(ctx context.Context, since int64, process func([]models.Audit) error) (string, error) {
+	s.auditsSince = since
+	if len(s.audits) == 0 {
+		return "", process(nil)
+	}
+	return fmt.Sprintf("after-%d", s.audits[0].Id), process(s.audits)
+}
+
+type fakeSink struct {
+	state   map[string]models.Checkpoint
+	tickets []models.Ticket
+	metrics []models.Ticket_metrics
+	runs    int
+}
+
+func newFakeSink() *fakeSink {
+	return &fakeSink{state: make(map[string]models.Checkpoint)}
+}
+
+func (s *fakeSink) RegisterTransformation(target string, fn func(interface{})) {}
+
+func (s *fakeSink) FetchCheckpoints(ctx context.Context) (map[string]models.Checkpoint, error) {
+	s.runs++
+	return s.state, nil
+}
+
+func (s *fakeSink) CommitCheckpoint(ctx context.Context, checkpoint models.Checkpoint) error {
+	s.state[checkpoint.Name] = checkpoint
+	return nil
+}
+
+func (s *fakeSink) commit(checkpoints []models.Checkpoint) error {
+	for _, checkpoint := range checkpoints {
+		s.state[checkpoint.Name] = checkpoint
+	}
+	return nil
+}
+
+func (s *fakeSink) ImportTicketFields(ctx context.Context, entities []models.Ticket_field, checkpoints ...models.Checkpoint) error {
+	return s.commit(checkpoints)
+}
+
+func (s *fakeSink) ImportGroups(ctx context.Context, entities []models.Group, checkpoints ...models.Checkpoint) error {
+	return s.commit(checkpoints)
+}
+
+func (s *fakeSink) ImportOrganizations(ctx context.Context, entities []models.Organization, checkpoints ...models.Checkpoint) error {
+	return s.commit(checkpoints)
+}
+
+func (s *fakeSink) ImportUsers(ctx context.Context, entities []models.User, checkpoints ...models.Checkpoint) error {
+	return s.commit(checkpoints)
+}
+
+func (s *fakeSink) ImportTicketMetrics(ctx context.Context, entities []models.Ticket_metrics, checkpoints ...models.Checkpoint) error {
+	s.metrics = append(s.metrics, entities...)
+	return s.commit(checkpoints)
+}
+
+func (s *fakeSink) ImportAudit(ctx context.Context, entities []models.Audit, checkpoints ...models.Checkpoint) error {
+	return s.commit(checkpoints)
+}
+
+func (s *fakeSink) ImportTickets(ctx context.Context, page models.Ticket_page, checkpoints ...models.Checkpoint) error {
+	s.tickets = append(s.tickets, page.Tickets...)
+	s.metrics = append(s.metrics, page.Metric_sets...)
+	return s.commit(checkpoints)
+}
+
+func TestRunOnceCommitsCheckpoints(t *testing.T) {
+	source := &fakeSource{tickets: []models.Ticket{{Id: 1}, {Id: 2}}}
+	sink := newFakeSink()
+
+	var processed bool
+	pipeline := NewPipeline(source, sink, MINUTE)
+	pipeline.RegisterPostProcessing(func(context.Context, Sink) error {
+		processed = true
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := pipeline.RunOnce(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(sink.tickets) != 4 {
+		t.Errorf("expected 4 imported tickets, got %d", len(sink.tickets))
+	}
+	if len(sink.metrics) != 4 || sink.metrics[1].Ticket_id != 2 {
+		t.Errorf("expected sideloaded metrics for every imported ticket, got %v", sink.metrics)
+	}
+	if sink.state[ORGANIZATION_EXPORT].Time().Unix() != 20 {
+		t.Errorf("unexpected organization checkpoint %v", sink.state[ORGANIZATION_EXPORT])
+	}
+	if sink.state[TICKET_EXPORT].Cursor() != "60" || sink.state[USER_EXPORT].Cursor() != "40" {
+		t.Errorf("unexpected cursors %v", sink.state)
+	}
+	if run := sink.state[TICKET_EXPORT].Run_id; run == "" || sink.state[USER_EXPORT].Run_id != run {
+		t.Errorf("expected checkpoints stamped with the same run, got %v", sink.state)
+	}
+	if !processed {
+		t.Error("post processing was not run")
+	}
+}
+
+func TestRunStopsOnPostProcessingError(t *testing.T) {
+	sink := newFakeSink()
+	failure := errors.New("post processing failed")
+
+	pipeline := NewPipeline(&fakeSource{}, sink, time.Millisecond)
+	pipeline.RegisterPostProcessing(func(context.Context, Sink) error { return failure })
+
+	if err := pipeline.Run(context.Background()); err != failure {
+		t.Fatalf("expected %v, got %v", failure, err)
+	}
+	if sink.runs != 1 {
+		t.Errorf("expected a single run, got %d", sink.runs)
+	}
+}
+
+func TestRunHonoursCancellation(t *testing.T) {
+	sink := newFakeSink()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := NewPipeline(&fakeSource{}, sink, time.Millisecond).Run(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if sink.runs < 2 {
+		t.Errorf("expected repeated runs, got %d", sink.runs)
+	}
+}
+
+func TestFailedExportIsNotCommitted(t *testing.T) {
+	sink := newFakeSink()
+	failure := errors.New("bad gateway")
+
+	err := NewPipeline(&fakeSource{failures: 1, ticketErr: failure}, sink, MINUTE).RunOnce(context.Background())
+	if err != failure {
+		t.Fatalf("expected %v, got %v", failure, err)
+	}
+	if _, ok := sink.state[TICKET_EXPORT]; ok {
+		t.Errorf("ticket checkpoint committed after a failed export: %v", sink.state)
+	}
+	if sink.state[USER_EXPORT].Cursor() != "20" {
+		t.Errorf("expected user cursor 20, got %v", sink.state[USER_EXPORT])
+	}
+}
+
+func TestRunRetriesTemporaryErrors(t *testing.T) {
+	sink := newFakeSink()
+	// wrapped as sinks wrap the errors of their drivers
+	source := &fakeSource{failures: 2, ticketErr: fmt.Errorf("SQLException: failed to insert 1 into tickets: %w", temporaryError{})}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := NewPipeline(source, sink, time.Millisecond).Run(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if sink.state[TICKET_EXPORT].Cursor() == "" {
+		t.Error("ticket cursor was never committed after recovering")
+	}
+}
+
+func TestCursorExportsResumeFromTimestamp(t *testing.T) {
+	sink := newFakeSink()
+	sink.state[TICKET_EXPORT] = models.TimestampCheckpoint(TICKET_EXPORT, time.Unix(100, 0))
+
+	if err := NewPipeline(&fakeSource{}, sink, MINUTE).RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if sink.state[TICKET_EXPORT].Cursor() != "115" {
+		t.Errorf("expected the first cursor export to start from the timestamp, got %v", sink.state[TICKET_EXPORT])
+	}
+}
+
+func TestAuditCheckpointKeepsCursor(t *testing.T) {
+	sink := newFakeSink()
+	sink.state[TICKET_AUDIT] = models.IntCheckpoint(TICKET_AUDIT, 7)
+
+	source := &fakeSource{audits: []models.Audit{{Id: 12}, {Id: 9}}}
+	if err := NewPipeline(source, sink, MINUTE).RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if source.auditsSince != 7 {
+		t.Errorf("expected audits exported since 7, got %d", source.auditsSince)
+	}
+
+	var position auditPosition
+	if err := sink.state[TICKET_AUDIT].Decode(&position); err != nil {
+		t.Fatal(err)
+	}
+	if position.Id != 12 || position.Cursor != "after-12" {
+		t.Errorf("expected audit 12 and its cursor, got %+v", position)
+	}
+}
+
+func TestInterruptedExportResumesAfterLastPage(t *testing.T) {
+	sink := newFakeSink()
+	failure := errors.New("connection reset")
+	source := &fakeSource{tickets: []models.Ticket{{Id: 1}, {Id: 2}}, failures: 1, ticketErr: failure}
+	pipeline := NewPipeline(source, sink, MINUTE)
+
+	if err := pipeline.RunOnce(context.Background()); err != failure {
+		t.Fatalf("expected %v, got %v", failure, err)
+	}
+	if sink.state[TICKET_EXPORT].Cursor() != "15" || len(sink.tickets) != 1 {
+		t.Fatalf("expected the first page and its cursor to be persisted, got %v and %d tickets",
+			sink.state[TICKET_EXPORT], len(sink.tickets))
+	}
+
+	if err := pipeline.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if sink.state[TICKET_EXPORT].Cursor() != "45" {
+		t.Errorf("expected the next run to resume from 15, got %v", sink.state[TICKET_EXPORT])
+	}
+}
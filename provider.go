@@ -0,0 +1,63 @@
+package zendb
+
+import (
+	"context"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+// Source - provider zendesk resources are read from
+// Each List/Export method hands every page it fetches to process and returns the value
+// the next run should resume from. On error the returned value is only as far as the pages already processed.
+// Incremental exports also hand process the position to resume from once that page is persisted.
+// Cursor based exports resume from cursor, or from since when there is no cursor yet.
+// An error from process stops the export and is returned as is.
+type Source interface {
+	ListTicketFields(ctx context.Context, process func([]models.Ticket_field) error) (last int64, err error)
+	ListGroups(ctx context.Context, process func([]models.Group) error) (last int64, err error)
+	ExportOrganizations(ctx context.Context, since int64, process func(page []models.Organization, next int64) error) (last int64, err error)
+	ExportUsers(ctx context.Context, since int64, cursor string, process func(page []models.User, next string) error) (last string, err error)
+	ExportTickets(ctx context.Context, since int64, cursor string, process func(page models.Ticket_page, next string) error) (last string, err error)
+	ExportTicketMetrics(ctx context.Context, tickets []int64, process func([]models.Ticket_metrics) error) (last int64, err error)
+	ExportTicketAudits(ctx context.Context, since int64, process func([]models.Audit) error) (last string, err error)
+}
+
+// Sink - provider zendesk resources are written to
+// FetchCheckpoints and CommitCheckpoint track progress between runs, keyed by checkpoint name.
+// Imports persist the given checkpoints atomically with their entities, imports cancelled through ctx are rolled back.
+type Sink interface {
+	RegisterTransformation(target string, fn func(interface{}))
+
+	FetchCheckpoints(ctx context.Context) (checkpoints map[string]models.Checkpoint, err error)
+	CommitCheckpoint(ctx context.Context, checkpoint models.Checkpoint) error
+
+	ImportTicketFields(ctx context.Context, entities []models.Ticket_field, checkpoints ...models.Checkpoint) error
+	ImportGroups(ctx context.Context, entities []models.Group, checkpoints ...models.Checkpoint) error
+	ImportOrganizations(ctx context.Context, entities []models.Organization, checkpoints ...models.Checkpoint) error
+	ImportUsers(ctx context.Context, entities []models.User, checkpoints ...models.Checkpoint) error
+	ImportTickets(ctx context.Context, page models.Ticket_page, checkpoints ...models.Checkpoint) error
+	ImportTicketMetrics(ctx context.Context, entities []models.Ticket_metrics, checkpoints ...models.Checkpoint) error
+	ImportAudit(ctx context.Context, entities []models.Audit, checkpoints ...models.Checkpoint) error
+}
+
+// Execer - optionally implemented by sinks which accept raw queries, used for post processing
+type Execer interface {
+	ExecRaw(ctx context.Context, qry string) (int64, error)
+}
+
+// Dialect - optionally implemented by Execers whose raw queries are not written for mysql, e.g. "postgres"
+type Dialect interface {
+	Dialect() string
+}
+
+// Resetter - optionally implemented by sinks which can discard a committed checkpoint
+type Resetter interface {
+	ResetCheckpoint(ctx context.Context, name string) error
+}
+
+// Migrator - optionally implemented by sinks which version their own schema
+type Migrator interface {
+	// Migrate - applies or reverts migrations until the schema is at version, a negative version applies them all
+	Migrate(ctx context.Context, version int64) (int64, error)
+	SchemaVersion(ctx context.Context) (int64, error)
+}
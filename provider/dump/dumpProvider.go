@@ -0,0 +1,492 @@
+package dump
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+	"github.com/rnpridgeon/zendb/provider/sink"
+)
+
+// targets, named as in the mysql sink so transformations register against either. Each is a file per run.
+const (
+	TICKET_FIELDS       = sink.TICKET_FIELDS
+	TICKET_FIELD_VALUES = sink.TICKET_FIELD_VALUES
+
+	GROUPS        = sink.GROUPS
+	ORGANIZATIONS = sink.ORGANIZATIONS
+	USERS         = sink.USERS
+	TICKETS       = sink.TICKETS
+
+	TICKET_METRICS = sink.TICKET_METRICS
+	TICKET_AUDITS  = sink.TICKET_AUDITS
+)
+
+// Formats
+const (
+	NDJSON = "ndjson"
+	CSV    = "csv"
+)
+
+// CHECKPOINTS - file under the sink's path holding the committed checkpoints
+const CHECKPOINTS = "checkpoints.json"
+
+// same layout as the pipeline's run ids, names the run of imports which carry none
+const runFormat = "20060102T150405.000000000Z"
+
+// DumpConfig - see the dump section of the README
+type DumpConfig struct {
+	Type string `json:"type"`
+	// directory runs and checkpoints are written to, created if missing
+	Path string `json:"path"`
+	// ndjson and/or csv, both by default
+	Formats []string `json:"formats"`
+	Gzip    bool     `json:"gzip"`
+	// bytes a file may grow to before the next page goes to a new one, zero never rotates
+	MaxFileSize int64 `json:"max_file_size"`
+}
+
+// table - csv columns of a target, tickets add a column per custom field
+type table struct {
+	out    *output
+	cols   flat
+	custom []int64
+}
+
+// page - entities of one target written by an import
+type page struct {
+	target string
+	model  interface{}
+	rows   []interface{}
+}
+
+// DumpProvider - writes every run to <path>/<run id>/, each target to <target>-0001.ndjson and <target>-0001.csv
+// numbered onwards as files are rotated. Checkpoints are committed once the rows they cover are on disk, rows of
+// an interrupted import are exported again by the next run. A single process may write to path at a time.
+type DumpProvider struct {
+	path            string
+	formats         []string
+	gzip            bool
+	maxSize         int64
+	transformations sink.Transformations
+
+	mu          sync.Mutex
+	run         string
+	tables      map[string]*table
+	fields      map[int64]string
+	checkpoints map[string]models.Checkpoint
+	// last page received of each resource, see Put
+	raw map[string]records
+
+	now func() time.Time
+}
+
+func Open(conf *DumpConfig) (*DumpProvider, error) {
+	if conf.Path == "" {
+		return nil, fmt.Errorf("the dump sink requires a path")
+	}
+
+	formats := conf.Formats
+	if len(formats) == 0 {
+		formats = []string{NDJSON, CSV}
+	}
+	for _, format := range formats {
+		if format != NDJSON && format != CSV {
+			return nil, fmt.Errorf("unsupported format %q, expected %s or %s", format, NDJSON, CSV)
+		}
+	}
+
+	if err := os.MkdirAll(conf.Path, 0755); err != nil {
+		return nil, err
+	}
+	checkpoints := make(map[string]models.Checkpoint)
+	raw, err := ioutil.ReadFile(filepath.Join(conf.Path, CHECKPOINTS))
+	switch {
+	case err == nil:
+		if err = json.Unmarshal(raw, &checkpoints); err != nil {
+			return nil, fmt.Errorf("corrupt %s: %w", CHECKPOINTS, err)
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	return &DumpProvider{
+		path:            conf.Path,
+		formats:         formats,
+		gzip:            conf.Gzip,
+		maxSize:         conf.MaxFileSize,
+		transformations: make(sink.Transformations),
+		tables:          make(map[string]*table),
+		fields:          make(map[int64]string),
+		checkpoints:     checkpoints,
+		raw:             make(map[string]records),
+		now:             time.Now,
+	}, nil
+}
+
+// Close - completes the files of the current run, gzip files are only complete once closed
+func (p *DumpProvider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.closeRun()
+}
+
+func (p *DumpProvider) closeRun() error {
+	var err error
+	for key, t := range p.tables {
+		if closeErr := t.out.close(); err == nil {
+			err = closeErr
+		}
+		delete(p.tables, key)
+	}
+	return err
+}
+
+func (p *DumpProvider) RegisterTransformation(target string, fn func(interface{})) {
+	p.transformations.Register(target, fn)
+}
+
+func (p *DumpProvider) FetchCheckpoints(ctx context.Context) (map[string]models.Checkpoint, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	checkpoints := make(map[string]models.Checkpoint, len(p.checkpoints))
+	for name, c := range p.checkpoints {
+		checkpoints[name] = c
+	}
+	return checkpoints, nil
+}
+
+func (p *DumpProvider) CommitCheckpoint(ctx context.Context, checkpoint models.Checkpoint) error {
+	return p.dump(ctx, []models.Checkpoint{checkpoint})
+}
+
+func (p *DumpProvider) ResetCheckpoint(ctx context.Context, name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	checkpoints := make(map[string]models.Checkpoint, len(p.checkpoints))
+	for n, c := range p.checkpoints {
+		if n != name {
+			checkpoints[n] = c
+		}
+	}
+	return p.save(checkpoints)
+}
+
+// dump - writes pages to the run the checkpoints belong to and commits the checkpoints once the rows are on disk
+func (p *DumpProvider) dump(ctx context.Context, checkpoints []models.Checkpoint, pages ...page) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.begin(checkpoints); err != nil {
+		return err
+	}
+
+	var written []*table
+	for _, pg := range pages {
+		if len(pg.rows) == 0 {
+			continue
+		}
+		tables, err := p.write(pg)
+		if err != nil {
+			return err
+		}
+		written = append(written, tables...)
+	}
+	for _, t := range written {
+		if err := t.out.flush(); err != nil {
+			return fmt.Errorf("failed to flush %s: %w", t.out.name, err)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(checkpoints) == 0 {
+		return nil
+	}
+
+	now := p.now()
+	next := make(map[string]models.Checkpoint, len(p.checkpoints)+len(checkpoints))
+	for name, c := range p.checkpoints {
+		next[name] = c
+	}
+	for _, c := range checkpoints {
+		if c.Written_at.IsZero() {
+			c.Written_at = now
+		}
+		if prev, ok := next[c.Name]; ok && !c.Advances(prev) {
+			continue
+		}
+		next[c.Name] = c
+	}
+	return p.save(next)
+}
+
+// begin - imports stamped with a new run start new files, those without a run id belong to the run in progress
+func (p *DumpProvider) begin(checkpoints []models.Checkpoint) error {
+	run := ""
+	for _, c := range checkpoints {
+		if c.Run_id != "" {
+			run = c.Run_id
+			break
+		}
+	}
+
+	switch {
+	case run == "" && p.run != "":
+		return nil
+	case run == "":
+		run = p.now().UTC().Format(runFormat)
+	case run == p.run:
+		return nil
+	}
+
+	err := p.closeRun()
+	p.run = run
+	return err
+}
+
+// save - replaces the checkpoints file in one step so a crash leaves either the old or the new checkpoints
+func (p *DumpProvider) save(checkpoints map[string]models.Checkpoint) error {
+	raw, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(p.path, CHECKPOINTS)
+	if err = ioutil.WriteFile(path+".tmp", raw, 0644); err != nil {
+		return err
+	}
+	if err = os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to commit %s: %w", CHECKPOINTS, err)
+	}
+	p.checkpoints = checkpoints
+	return nil
+}
+
+// write - appends pg to the target's file in every format
+func (p *DumpProvider) write(pg page) ([]*table, error) {
+	var tables []*table
+	for _, format := range p.formats {
+		t := p.table(pg.target, format, pg.model)
+		tables = append(tables, t)
+
+		if format == NDJSON {
+			w, err := t.out.writer()
+			if err != nil {
+				return nil, err
+			}
+			enc := json.NewEncoder(w)
+			for _, row := range pg.rows {
+				if rec, ok := p.rawRecord(pg.target, row); ok {
+					err = writeLine(w, rec)
+				} else {
+					err = enc.Encode(row)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		for _, row := range pg.rows {
+			record, err := t.cols.record(row)
+			if err != nil {
+				return nil, err
+			}
+			if t.custom != nil {
+				custom, err := customRecord(t.custom, row.(models.Ticket).Custom_fields)
+				if err != nil {
+					return nil, err
+				}
+				record = append(record, custom...)
+			}
+			if err = t.out.writeRecord(record); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return tables, nil
+}
+
+func (p *DumpProvider) table(target, format string, model interface{}) *table {
+	key := target + "." + format
+	if t, ok := p.tables[key]; ok {
+		return t
+	}
+
+	t := &table{out: &output{
+		dir:     filepath.Join(p.path, p.run),
+		name:    target,
+		ext:     "." + format,
+		gzip:    p.gzip,
+		maxSize: p.maxSize,
+	}}
+	if format == CSV {
+		if target == TICKETS {
+			t.cols = flatten(model, "custom_fields")
+			ids, titles := customColumns(p.fields)
+			t.custom = ids
+			t.out.header = append(append([]string{}, t.cols.columns...), titles...)
+		} else {
+			t.cols = flatten(model)
+			t.out.header = t.cols.columns
+		}
+	}
+	p.tables[key] = t
+	return t
+}
+
+// ImportTicketFields - titles name the custom field columns of tickets.csv, a change of fields starts a new file
+func (p *DumpProvider) ImportTicketFields(ctx context.Context, entities []models.Ticket_field, checkpoints ...models.Checkpoint) error {
+	rows := make([]interface{}, 0, len(entities))
+	fields := make(map[int64]string, len(entities))
+	last := p.transformations.TicketFields(entities, func(e models.Ticket_field) {
+		rows = append(rows, e)
+		fields[e.Id] = e.Title
+	})
+
+	if err := p.updateFields(fields); err != nil {
+		return err
+	}
+	return p.dump(ctx, append(checkpoints, models.IntCheckpoint(TICKET_FIELDS, last)), page{TICKET_FIELDS, models.Ticket_field{}, rows})
+}
+
+// updateFields - merges fields into the known ticket fields, completing tickets.csv if they change its columns
+func (p *DumpProvider) updateFields(fields map[int64]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	changed := false
+	for id, title := range fields {
+		if prev, ok := p.fields[id]; !ok || prev != title {
+			p.fields[id] = title
+			changed = true
+		}
+	}
+
+	key := TICKETS + "." + CSV
+	t, ok := p.tables[key]
+	if !changed || !ok {
+		return nil
+	}
+	delete(p.tables, key)
+
+	// the next file of the same run continues the numbering
+	next := p.table(TICKETS, CSV, models.Ticket{})
+	next.out.seq = t.out.seq
+	return t.out.close()
+}
+
+func (p *DumpProvider) ImportGroups(ctx context.Context, entities []models.Group, checkpoints ...models.Checkpoint) error {
+	rows, last := p.groupRows(entities)
+	return p.dump(ctx, append(checkpoints, models.IntCheckpoint(GROUPS, last)), page{GROUPS, models.Group{}, rows})
+}
+
+func (p *DumpProvider) ImportOrganizations(ctx context.Context, entities []models.Organization, checkpoints ...models.Checkpoint) error {
+	defer sink.TimeTrack(time.Now(), "Organization Import")
+
+	rows, last := p.organizationRows(entities)
+	return p.dump(ctx, append(checkpoints, models.IntCheckpoint(ORGANIZATIONS, last)), page{ORGANIZATIONS, models.Organization{}, rows})
+}
+
+func (p *DumpProvider) ImportUsers(ctx context.Context, entities []models.User, checkpoints ...models.Checkpoint) error {
+	defer sink.TimeTrack(time.Now(), "User import")
+
+	rows, last := p.userRows(entities)
+	return p.dump(ctx, append(checkpoints, models.IntCheckpoint(USERS, last)), page{USERS, models.User{}, rows})
+}
+
+// ImportTickets - sideloaded records go to the files of their own targets
+func (p *DumpProvider) ImportTickets(ctx context.Context, pg models.Ticket_page, checkpoints ...models.Checkpoint) error {
+	defer sink.TimeTrack(time.Now(), "Ticket import")
+
+	groups, lastGroup := p.groupRows(pg.Groups)
+	organizations, lastOrganization := p.organizationRows(pg.Organizations)
+	users, lastUser := p.userRows(pg.Users)
+	tickets, lastTicket := p.ticketRows(pg.Tickets)
+	metrics, lastMetric := p.ticketMetricRows(pg.Metric_sets)
+
+	pages := []page{
+		{GROUPS, models.Group{}, groups},
+		{ORGANIZATIONS, models.Organization{}, organizations},
+		{USERS, models.User{}, users},
+		{TICKETS, models.Ticket{}, tickets},
+		{TICKET_METRICS, models.Ticket_metrics{}, metrics},
+	}
+	for i, last := range []int64{lastGroup, lastOrganization, lastUser, lastTicket, lastMetric} {
+		if len(pages[i].rows) > 0 {
+			checkpoints = append(checkpoints, models.IntCheckpoint(pages[i].target, last))
+		}
+	}
+	return p.dump(ctx, checkpoints, pages...)
+}
+
+func (p *DumpProvider) ImportTicketMetrics(ctx context.Context, entities []models.Ticket_metrics, checkpoints ...models.Checkpoint) error {
+	rows, last := p.ticketMetricRows(entities)
+	return p.dump(ctx, append(checkpoints, models.IntCheckpoint(TICKET_METRICS, last)), page{TICKET_METRICS, models.Ticket_metrics{}, rows})
+}
+
+// ImportAudit - every audit is written along with all of its events, as with the sql sinks no table checkpoint
+// is written
+func (p *DumpProvider) ImportAudit(ctx context.Context, entities []models.Audit, checkpoints ...models.Checkpoint) error {
+	defer sink.TimeTrack(time.Now(), "Audit import")
+
+	rows := make([]interface{}, 0, len(entities))
+	p.transformations.Audits(entities, func(e models.Audit) {
+		rows = append(rows, e)
+	})
+
+	return p.dump(ctx, checkpoints, page{TICKET_AUDITS, models.Audit{}, rows})
+}
+
+func (p *DumpProvider) groupRows(entities []models.Group) (rows []interface{}, last int64) {
+	last = p.transformations.Groups(entities, func(e models.Group) {
+		rows = append(rows, e)
+	})
+	return rows, last
+}
+
+func (p *DumpProvider) organizationRows(entities []models.Organization) (rows []interface{}, last int64) {
+	last = p.transformations.Organizations(entities, func(e models.Organization) {
+		rows = append(rows, e)
+	})
+	return rows, last
+}
+
+func (p *DumpProvider) userRows(entities []models.User) (rows []interface{}, last int64) {
+	last = p.transformations.Users(entities, func(e models.User) {
+		rows = append(rows, e)
+	})
+	return rows, last
+}
+
+// ticketRows - custom field transformations apply to the values expanded into tickets.csv
+func (p *DumpProvider) ticketRows(entities []models.Ticket) (rows []interface{}, last int64) {
+	last = p.transformations.Tickets(entities, func(e models.Ticket) {
+		rows = append(rows, e)
+	})
+	return rows, last
+}
+
+// ticketMetricRows - as with the sql sinks the last ticket is the highest solved one
+func (p *DumpProvider) ticketMetricRows(entities []models.Ticket_metrics) (rows []interface{}, last int64) {
+	last = p.transformations.TicketMetrics(entities, func(e models.Ticket_metrics) {
+		rows = append(rows, e)
+	})
+	return rows, last
+}
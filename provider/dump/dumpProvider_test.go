@@ -0,0 +1,209 @@
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+func open(t *testing.T, conf DumpConfig) *DumpProvider {
+	p, err := Open(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.now = func() time.Time { return time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC) }
+	return p
+}
+
+func readCSV(t *testing.T, path string) [][]string {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestImportTicketsWritesNDJSONAndCSV(t *testing.T) {
+	dir := t.TempDir()
+	p := open(t, DumpConfig{Path: dir})
+	ctx := context.Background()
+
+	fields := []models.Ticket_field{{Id: 2, Title: "Version"}, {Id: 1, Title: "Component"}}
+	if err := p.ImportTicketFields(ctx, fields, models.IntCheckpoint("ticket_fields", 2)); err != nil {
+		t.Fatal(err)
+	}
+	page := models.Ticket_page{
+		Tickets: []models.Ticket{{Id: 11, Subject: "broker down", Custom_fields: []models.Custom_fields{
+			{Id: 1, Value: "kafka_broker", Transformed: "broker"}, {Id: 2, Value: "3.1"}}}},
+		Users: []models.User{{Id: 7, Name: "jane"}},
+	}
+	run := models.CursorCheckpoint("ticket_export", "abc")
+	run.Run_id = "run-1"
+	if err := p.ImportTickets(ctx, page, run); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "run-1", "tickets-0001.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []models.Ticket
+	for s := bufio.NewScanner(f); s.Scan(); {
+		var ticket models.Ticket
+		if err = json.Unmarshal(s.Bytes(), &ticket); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, ticket)
+	}
+	if len(lines) != 1 || lines[0].Subject != "broker down" {
+		t.Errorf("expected the ticket as a single line, got %v", lines)
+	}
+
+	records := readCSV(t, filepath.Join(dir, "run-1", "tickets-0001.csv"))
+	if len(records) != 2 {
+		t.Fatalf("expected a header and a ticket, got %v", records)
+	}
+	header, ticket := records[0], records[1]
+	if header[0] != "id" || ticket[0] != "11" {
+		t.Errorf("expected the id first, got %v and %v", header, ticket)
+	}
+	n := len(header)
+	if header[n-2] != "Component" || header[n-1] != "Version" || ticket[n-2] != "broker" || ticket[n-1] != "3.1" {
+		t.Errorf("expected custom fields as the last columns, got %v and %v", header[n-2:], ticket[n-2:])
+	}
+	if contains(header, "custom_fields") {
+		t.Errorf("expected custom fields to be expanded, got %v", header)
+	}
+
+	if users := readCSV(t, filepath.Join(dir, "run-1", "users-0001.csv")); len(users) != 2 {
+		t.Errorf("expected sideloaded users in their own file, got %v", users)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "run-1", "groups-0001.csv")); !os.IsNotExist(err) {
+		t.Errorf("expected no file for a resource without rows, got %v", err)
+	}
+}
+
+func TestNDJSONWritesPagesAsReceived(t *testing.T) {
+	dir := t.TempDir()
+	p := open(t, DumpConfig{Path: dir, Formats: []string{NDJSON}})
+	ctx := context.Background()
+
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	zw.Write([]byte("{\n  \"users\": [\n    {\"id\": 7, \"name\": \"jane\", \"locale\": \"en-US\"}\n  ],\n  \"end_of_stream\": true\n}"))
+	zw.Close()
+	if err := p.Put(ctx, "users/20200301T120000.000000000Z/000001_abc.json.gz", body.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	p.RegisterTransformation(USERS, func(e interface{}) { e.(*models.User).Name = "transformed" })
+	if err := p.ImportUsers(ctx, []models.User{{Id: 7, Name: "jane"}, {Id: 8, Name: "john"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Join(dir, "20200301T120000.000000000Z", "users-0001.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(raw)), "\n")
+	if len(lines) != 2 || lines[0] != `{"id":7,"name":"jane","locale":"en-US"}` {
+		t.Fatalf("expected the user as received, got %q", lines)
+	}
+	var user models.User
+	if err = json.Unmarshal([]byte(lines[1]), &user); err != nil || user.Id != 8 || user.Name != "transformed" {
+		t.Errorf("expected a user missing from the page to be written as decoded, got %s: %v", lines[1], err)
+	}
+}
+
+func TestGzipFilesRotate(t *testing.T) {
+	dir := t.TempDir()
+	p := open(t, DumpConfig{Path: dir, Formats: []string{NDJSON}, Gzip: true, MaxFileSize: 1})
+	ctx := context.Background()
+
+	for id := int64(1); id <= 2; id++ {
+		if err := p.ImportGroups(ctx, []models.Group{{Id: id, Name: "support"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	run := filepath.Join(dir, "20200301T120000.000000000Z")
+	for i, name := range []string{"groups-0001.ndjson.gz", "groups-0002.ndjson.gz"} {
+		f, err := os.Open(filepath.Join(run, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var group models.Group
+		if err = json.NewDecoder(zr).Decode(&group); err != nil || group.Id != int64(i+1) {
+			t.Errorf("expected group %d in %s, got %v %v", i+1, name, group, err)
+		}
+		f.Close()
+	}
+}
+
+func TestCheckpointsArePersisted(t *testing.T) {
+	dir := t.TempDir()
+	p := open(t, DumpConfig{Path: dir})
+	ctx := context.Background()
+
+	if err := p.ImportUsers(ctx, []models.User{{Id: 5}}, models.CursorCheckpoint("user_export", "abc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CommitCheckpoint(ctx, models.IntCheckpoint(USERS, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoints, err := open(t, DumpConfig{Path: dir}).FetchCheckpoints(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoints["user_export"].Cursor() != "abc" || checkpoints[USERS].Int() != 5 {
+		t.Errorf("expected checkpoints to be read back without moving backwards, got %v", checkpoints)
+	}
+
+	if err = p.ResetCheckpoint(ctx, "user_export"); err != nil {
+		t.Fatal(err)
+	}
+	if checkpoints, _ = open(t, DumpConfig{Path: dir}).FetchCheckpoints(ctx); len(checkpoints) != 1 {
+		t.Errorf("expected the reset to discard the checkpoint, got %v", checkpoints)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err = p.ImportUsers(cancelled, []models.User{{Id: 9}}); err != context.Canceled {
+		t.Errorf("expected the import to be cancelled, got %v", err)
+	}
+	if checkpoints, _ = p.FetchCheckpoints(ctx); checkpoints[USERS].Int() != 5 {
+		t.Errorf("expected the cancelled import not to commit, got %v", checkpoints)
+	}
+}
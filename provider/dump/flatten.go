@@ -0,0 +1,150 @@
+package dump
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// flat - csv columns of a model, one per exported field named as the field in lower case like the sql sinks'
+// columns. Times are RFC3339 and empty while unset, nested values are JSON.
+type flat struct {
+	columns []string
+	index   [][]int
+}
+
+func flatten(model interface{}, skip ...string) flat {
+	var f flat
+	f.add(reflect.TypeOf(model), nil, skip)
+	return f
+}
+
+func (f *flat) add(t reflect.Type, parent []int, skip []string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		index := append(append([]int{}, parent...), i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Type != timeType {
+			f.add(field.Type, index, skip)
+			continue
+		}
+		name := strings.ToLower(field.Name)
+		if field.PkgPath != "" || contains(skip, name) {
+			continue
+		}
+
+		f.columns = append(f.columns, name)
+		f.index = append(f.index, index)
+	}
+}
+
+func (f flat) record(entity interface{}) ([]string, error) {
+	val := reflect.ValueOf(entity)
+	record := make([]string, len(f.index))
+	for i, index := range f.index {
+		cell, err := format(val.FieldByIndex(index).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("failed to flatten %s: %w", f.columns[i], err)
+		}
+		record[i] = cell
+	}
+	return record, nil
+}
+
+// format - a single csv cell
+func format(val interface{}) (string, error) {
+	switch v := val.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		if v.IsZero() {
+			return "", nil
+		}
+		return v.UTC().Format(time.RFC3339), nil
+	case *time.Time:
+		if v == nil {
+			return "", nil
+		}
+		return format(*v)
+	}
+
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			return "", nil
+		}
+	}
+	raw, err := json.Marshal(val)
+	return string(raw), err
+}
+
+// customColumns - one column per ticket field, ordered by id and named by title. Titles which are not unique
+// are suffixed with the field's id.
+func customColumns(fields map[int64]string) ([]int64, []string) {
+	ids := make([]int64, 0, len(fields))
+	seen := make(map[string]int)
+	for id, title := range fields {
+		ids = append(ids, id)
+		seen[title]++
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	titles := make([]string, len(ids))
+	for i, id := range ids {
+		titles[i] = fields[id]
+		if seen[titles[i]] > 1 {
+			titles[i] = fmt.Sprintf("%s (%d)", titles[i], id)
+		}
+	}
+	return ids, titles
+}
+
+// customRecord - the ticket's custom field values in column order, transformed values are preferred
+func customRecord(ids []int64, values []models.Custom_fields) ([]string, error) {
+	byID := make(map[int64]models.Custom_fields, len(values))
+	for _, v := range values {
+		byID[v.Id] = v
+	}
+
+	record := make([]string, len(ids))
+	for i, id := range ids {
+		v, ok := byID[id]
+		if !ok {
+			continue
+		}
+		if v.Transformed != "" {
+			record[i] = v.Transformed
+			continue
+		}
+		cell, err := format(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		record[i] = cell
+	}
+	return record, nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package dump
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// countingWriter - tracks how large the file being written has grown
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// output - the files of one target in one format, numbered from 1 as they are rotated.
+// Files are opened on first write and rotated between pages once they exceed maxSize, zero never rotates.
+type output struct {
+	dir     string
+	name    string
+	ext     string
+	gzip    bool
+	maxSize int64
+	// header starts every csv file, nil for ndjson
+	header []string
+
+	seq  int
+	file *os.File
+	size *countingWriter
+	zw   *gzip.Writer
+	w    io.Writer
+	csv  *csv.Writer
+}
+
+func (o *output) open() error {
+	o.seq++
+	path := filepath.Join(o.dir, fmt.Sprintf("%s-%04d%s", o.name, o.seq, o.ext))
+	if o.gzip {
+		path += ".gz"
+	}
+
+	if err := os.MkdirAll(o.dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	o.file = f
+	o.size = &countingWriter{w: f}
+	o.w = o.size
+	if o.gzip {
+		o.zw = gzip.NewWriter(o.size)
+		o.w = o.zw
+	}
+
+	if o.header == nil {
+		return nil
+	}
+	o.csv = csv.NewWriter(o.w)
+	return o.csv.Write(o.header)
+}
+
+// writer - where the next record goes, opening a file if none is open
+func (o *output) writer() (io.Writer, error) {
+	if o.file == nil {
+		if err := o.open(); err != nil {
+			return nil, err
+		}
+	}
+	return o.w, nil
+}
+
+func (o *output) writeRecord(record []string) error {
+	if _, err := o.writer(); err != nil {
+		return err
+	}
+	return o.csv.Write(record)
+}
+
+// flush - pushes everything written so far to disk, a gzip stream flushed this way stays readable up to here
+// should the process die before the file is closed
+func (o *output) flush() error {
+	if o.file == nil {
+		return nil
+	}
+	if o.csv != nil {
+		o.csv.Flush()
+		if err := o.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if o.zw != nil {
+		if err := o.zw.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := o.file.Sync(); err != nil {
+		return err
+	}
+
+	if o.maxSize > 0 && o.size.n >= o.maxSize {
+		return o.close()
+	}
+	return nil
+}
+
+// close - completes the current file, the next write opens the next one
+func (o *output) close() error {
+	if o.file == nil {
+		return nil
+	}
+
+	var err error
+	if o.csv != nil {
+		o.csv.Flush()
+		err = o.csv.Error()
+	}
+	if o.zw != nil {
+		if closeErr := o.zw.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := o.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	o.file, o.size, o.zw, o.w, o.csv = nil, nil, nil, nil, nil
+	return err
+}
@@ -0,0 +1,128 @@
+package dump
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+// collections - the key each target's records are listed under in a zendesk page
+var collections = map[string]string{
+	TICKET_FIELDS:  "ticket_fields",
+	GROUPS:         "groups",
+	ORGANIZATIONS:  "organizations",
+	USERS:          "users",
+	TICKETS:        "tickets",
+	TICKET_METRICS: "metric_sets",
+	TICKET_AUDITS:  "audits",
+}
+
+// records - raw records of a page by collection and id
+type records map[string]map[int64]json.RawMessage
+
+// Put - receives every page as archived by the zendesk source, gzip compressed. The records of the last page of
+// each resource are kept until imported, NDJSON lines are written from them rather than from the decoded models.
+// A page which cannot be read is only logged, its records are then written as decoded.
+func (p *DumpProvider) Put(ctx context.Context, key string, body []byte) error {
+	page, err := readPage(body)
+	if err != nil {
+		log.Printf("WARN: %s will be dumped as decoded: %s", key, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.raw[strings.SplitN(key, "/", 2)[0]] = page
+	return nil
+}
+
+func readPage(body []byte) (records, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	body, err = ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	var page map[string]json.RawMessage
+	if err = json.Unmarshal(body, &page); err != nil {
+		return nil, err
+	}
+
+	recs := make(records)
+	for _, collection := range collections {
+		var list []json.RawMessage
+		if raw, ok := page[collection]; !ok || json.Unmarshal(raw, &list) != nil {
+			continue
+		}
+
+		recs[collection] = make(map[int64]json.RawMessage, len(list))
+		for _, rec := range list {
+			var id struct {
+				Id int64 `json:"id"`
+			}
+			if err = json.Unmarshal(rec, &id); err == nil {
+				recs[collection][id.Id] = rec
+			}
+		}
+	}
+	return recs, nil
+}
+
+// rawRecord - the record of target with row's id as zendesk sent it, if a page held one. Records are only written
+// once, a later import of the same id without a page of its own is written as decoded.
+func (p *DumpProvider) rawRecord(target string, row interface{}) (json.RawMessage, bool) {
+	collection, ok := collections[target]
+	if !ok {
+		return nil, false
+	}
+
+	id := idOf(row)
+	for _, page := range p.raw {
+		if rec, ok := page[collection][id]; ok {
+			delete(page[collection], id)
+			return rec, true
+		}
+	}
+	return nil, false
+}
+
+func idOf(row interface{}) int64 {
+	switch e := row.(type) {
+	case models.Ticket_field:
+		return e.Id
+	case models.Group:
+		return e.Id
+	case models.Organization:
+		return e.Id
+	case models.User:
+		return e.Id
+	case models.Ticket:
+		return e.Id
+	case models.Ticket_metrics:
+		return e.Id
+	case models.Audit:
+		return e.Id
+	}
+	return 0
+}
+
+// writeLine - rec on a line of its own, whatever the layout of the page it came from
+func writeLine(w io.Writer, rec json.RawMessage) error {
+	var line bytes.Buffer
+	if err := json.Compact(&line, rec); err != nil {
+		return err
+	}
+	line.WriteByte('\n')
+	_, err := w.Write(line.Bytes())
+	return err
+}
@@ -0,0 +1,126 @@
+package migration
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration - a numbered schema change along with the statements which revert it.
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load - every migration under dir in ascending version order
+func Load(files fs.FS, dir string) ([]Migration, error) {
+	paths, err := fs.Glob(files, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, p := range paths {
+		base := path.Base(p)
+		direction := path.Ext(strings.TrimSuffix(base, ".sql"))
+		parts := strings.SplitN(strings.TrimSuffix(base, direction+".sql"), "_", 2)
+
+		version, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || version <= 0 || len(parts) != 2 || (direction != ".up" && direction != ".down") {
+			return nil, fmt.Errorf("malformed migration name %s", base)
+		}
+
+		raw, err := fs.ReadFile(files, p)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		}
+		if m.Name != parts[1] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, parts[1])
+		}
+		if direction == ".up" {
+			m.Up = string(raw)
+		} else {
+			m.Down = string(raw)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %s requires both an up and a down file", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Step - a migration to apply, or with Revert set to undo
+type Step struct {
+	Migration
+	Revert bool
+	// version the schema is at once the step completes
+	Result int64
+}
+
+// Plan - the steps moving a schema at current to target, a negative target is the latest migration
+func Plan(migrations []Migration, current, target int64) []Step {
+	if target < 0 && len(migrations) > 0 {
+		target = migrations[len(migrations)-1].Version
+	}
+
+	var steps []Step
+	for _, m := range migrations {
+		if m.Version > current && m.Version <= target {
+			steps = append(steps, Step{Migration: m, Result: m.Version})
+		}
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version > current || m.Version <= target {
+			continue
+		}
+		step := Step{Migration: m, Revert: true}
+		if i > 0 {
+			step.Result = migrations[i-1].Version
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// Script - the statements the step runs
+func (s Step) Script() string {
+	if s.Revert {
+		return s.Down
+	}
+	return s.Up
+}
+
+// Statements - splits a script into the statements it holds, for drivers which run one statement per call
+func Statements(script string) []string {
+	var stmts []string
+	for _, stmt := range strings.Split(script, ";\n") {
+		if stmt = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(stmt), ";")); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
@@ -0,0 +1,84 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadOrdersMigrations(t *testing.T) {
+	files := fstest.MapFS{
+		"migrations/0002_b.up.sql":   {Data: []byte("SELECT 2;")},
+		"migrations/0002_b.down.sql": {Data: []byte("SELECT -2;")},
+		"migrations/0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+		"migrations/0001_a.down.sql": {Data: []byte("SELECT -1;")},
+	}
+
+	migrations, err := Load(files, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].String() != "0001_a" || migrations[1].Down != "SELECT -2;" {
+		t.Errorf("unexpected migrations %+v", migrations)
+	}
+}
+
+func TestLoadRejectsIncompleteSets(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"missing down": {
+			"migrations/0001_a.up.sql": {Data: []byte("SELECT 1;")},
+		},
+		"malformed name": {
+			"migrations/first.up.sql":   {Data: []byte("SELECT 1;")},
+			"migrations/first.down.sql": {Data: []byte("SELECT 1;")},
+		},
+		"conflicting names": {
+			"migrations/0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"migrations/0001_b.down.sql": {Data: []byte("SELECT 1;")},
+		},
+	}
+
+	for name, files := range cases {
+		if _, err := Load(files, "migrations"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	migrations := []Migration{{Version: 1, Name: "a"}, {Version: 2, Name: "b"}, {Version: 3, Name: "c"}}
+
+	cases := []struct {
+		name    string
+		current int64
+		target  int64
+		steps   []Step
+	}{
+		{"latest", 1, -1, []Step{{Migration: migrations[1], Result: 2}, {Migration: migrations[2], Result: 3}}},
+		{"up to", 0, 1, []Step{{Migration: migrations[0], Result: 1}}},
+		{"down to", 3, 1, []Step{{Migration: migrations[2], Revert: true, Result: 2}, {Migration: migrations[1], Revert: true, Result: 1}}},
+		{"to nothing", 1, 0, []Step{{Migration: migrations[0], Revert: true, Result: 0}}},
+		{"current", 3, 3, nil},
+	}
+
+	for _, c := range cases {
+		steps := Plan(migrations, c.current, c.target)
+		if len(steps) != len(c.steps) {
+			t.Errorf("%s: expected %d steps, got %+v", c.name, len(c.steps), steps)
+			continue
+		}
+		for i := range steps {
+			if steps[i] != c.steps[i] {
+				t.Errorf("%s: expected step %d to be %+v, got %+v", c.name, i, c.steps[i], steps[i])
+			}
+		}
+	}
+}
+
+func TestStatements(t *testing.T) {
+	script := "/* comment */\nCREATE TABLE a (id INT);\n\nINSERT INTO a VALUES (1);\nDROP TABLE a;"
+
+	stmts := Statements(script)
+	if len(stmts) != 3 || stmts[0] != "/* comment */\nCREATE TABLE a (id INT)" || stmts[2] != "DROP TABLE a" {
+		t.Errorf("unexpected statements %q", stmts)
+	}
+}
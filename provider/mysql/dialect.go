@@ -0,0 +1,102 @@
+package mysql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/rnpridgeon/zendb/provider/sqlsink"
+)
+
+// mysql refuses statements with more placeholders than this
+const maxPlaceholders = 65535
+
+// server errors worth retrying
+const (
+	tooManyConnections = 1040
+	lockWaitTimeout    = 1205
+	deadlock           = 1213
+)
+
+// dialect - times are stored as unix seconds, zero where unset
+type dialect struct{}
+
+func (dialect) Param(n int) string {
+	return "?"
+}
+
+func (dialect) Quote(name string) string {
+	return "`" + name + "`"
+}
+
+// OnConflict - ON DUPLICATE KEY UPDATE. mysql evaluates the guard of later assignments against columns already
+// updated by earlier ones, the column guarding the row is assigned last.
+func (d dialect) OnConflict(t sqlsink.Table) string {
+	updates := make([]string, 0, len(t.Updates))
+	for _, col := range t.Updates {
+		if col != t.Newer {
+			updates = append(updates, col)
+		}
+	}
+	if len(updates) < len(t.Updates) {
+		updates = append(updates, t.Newer)
+	}
+
+	assignments := make([]string, len(updates))
+	for i, col := range updates {
+		val := fmt.Sprintf("VALUES(%s)", d.Quote(col))
+		if t.Newer != "" {
+			val = fmt.Sprintf("IF(VALUES(%s) > %s, %s, %s)", d.Quote(t.Newer), d.Quote(t.Newer), val, d.Quote(col))
+		}
+		assignments[i] = fmt.Sprintf("%s = %s", d.Quote(col), val)
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ", ")
+}
+
+func (dialect) ForUpdate() string {
+	return " FOR UPDATE"
+}
+
+func (dialect) MaxParams() int {
+	return maxPlaceholders
+}
+
+func (dialect) Time(at time.Time) interface{} {
+	return at.Unix()
+}
+
+func (dialect) Unset() interface{} {
+	return int64(0)
+}
+
+// Value - strings, numbers and booleans are written as they are, the driver refuses anything else such as the
+// lists of multi-select and tagger fields, those are written as JSON
+func (dialect) Value(val interface{}) interface{} {
+	switch val.(type) {
+	case nil, string, bool, float64, float32, int64, int, json.Number:
+		return val
+	}
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return nil
+	}
+	return string(raw)
+}
+
+func (dialect) Savepoints() bool {
+	return false
+}
+
+func (dialect) Temporary(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case tooManyConnections, lockWaitTimeout, deadlock:
+			return true
+		}
+	}
+	return errors.Is(err, mysql.ErrInvalidConn)
+}
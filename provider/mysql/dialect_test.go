@@ -0,0 +1,46 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/rnpridgeon/zendb/provider/sqlsink"
+)
+
+func TestOnConflict(t *testing.T) {
+	groups := sqlsink.Table{Name: GROUPS, Columns: []string{"id", "name", "updated_at"}, Keys: []string{"id"},
+		Updates: []string{"name", "updated_at"}}
+	expected := "ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `updated_at` = VALUES(`updated_at`)"
+	if got := (dialect{}).OnConflict(groups); got != expected {
+		t.Errorf("expected\n\t%s\ngot\n\t%s", expected, got)
+	}
+
+	audits := sqlsink.Table{Name: TICKET_AUDITS, Columns: []string{"ticket_id", "audit_id", "value"}, Keys: []string{"ticket_id"},
+		Updates: []string{"audit_id", "value"}, Newer: "audit_id"}
+	guarded := (dialect{}).OnConflict(audits)
+	if !strings.HasSuffix(guarded, "`audit_id` = IF(VALUES(`audit_id`) > `audit_id`, VALUES(`audit_id`), `audit_id`)") {
+		t.Errorf("expected audit_id to be assigned last behind the guard, got %s", guarded)
+	}
+}
+
+func TestValueEncodesListsAsJSON(t *testing.T) {
+	if got := (dialect{}).Value([]interface{}{"kafka", "connect"}); got != `["kafka","connect"]` {
+		t.Errorf("expected a multi-select value as JSON, got %v", got)
+	}
+	for _, val := range []interface{}{"3.1", 4.0, true, nil} {
+		if got := (dialect{}).Value(val); got != val {
+			t.Errorf("expected %v to be written as is, got %v", val, got)
+		}
+	}
+}
+
+func TestTemporary(t *testing.T) {
+	if !(dialect{}).Temporary(fmt.Errorf("SQLException: %w", &mysql.MySQLError{Number: lockWaitTimeout})) {
+		t.Error("expected a lock wait timeout to be temporary")
+	}
+	if (dialect{}).Temporary(&mysql.MySQLError{Number: 1452}) {
+		t.Error("expected a foreign key violation not to be temporary")
+	}
+}
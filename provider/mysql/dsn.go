@@ -0,0 +1,142 @@
+package mysql
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TLS modes
+const (
+	// TLS_DISABLED - plain text connections, the default
+	TLS_DISABLED = "disabled"
+	// TLS_PREFERRED - use TLS when the server offers it, without verifying its certificate
+	TLS_PREFERRED = "preferred"
+	// TLS_SKIP_VERIFY - require TLS without verifying the server's certificate
+	TLS_SKIP_VERIFY = "skip-verify"
+	// TLS_VERIFY - require TLS and verify the server's certificate against ca, or the system roots
+	TLS_VERIFY = "verify"
+)
+
+const (
+	defaultDatabase = "zendb"
+	defaultProtocol = "tcp"
+	// matches the character set zendb is created with, utf8 silently truncates 4 byte characters
+	defaultCharset = "utf8mb4"
+
+	// name the verified TLS configuration is registered with the driver under
+	tlsConfigName = "zendb"
+)
+
+// TLSConfig - encryption of connections to the database, cert and key are only required for client authentication
+type TLSConfig struct {
+	Mode       string `json:"mode"`
+	CA         string `json:"ca"`
+	Cert       string `json:"cert"`
+	Key        string `json:"key"`
+	ServerName string `json:"server_name"`
+}
+
+// dsn - the driver connection string described by the configuration
+func (c *MysqlConfig) dsn() (string, error) {
+	cfg := mysql.NewConfig()
+	cfg.User = c.User
+	cfg.Passwd = c.Password
+	cfg.DBName = orDefault(c.Database, defaultDatabase)
+	cfg.Net = orDefault(c.Protocol, defaultProtocol)
+	cfg.Params = map[string]string{"charset": orDefault(c.Charset, defaultCharset)}
+
+	switch cfg.Net {
+	case "tcp":
+		cfg.Addr = net.JoinHostPort(c.Hostname, strconv.FormatUint(uint64(c.Port), 10))
+	case "unix":
+		if c.Socket == "" {
+			return "", fmt.Errorf("the unix protocol requires a socket path")
+		}
+		cfg.Addr = c.Socket
+	default:
+		return "", fmt.Errorf("unsupported protocol %q, expected tcp or unix", c.Protocol)
+	}
+
+	if c.TLS != nil {
+		mode, err := c.TLS.register(c.Hostname)
+		if err != nil {
+			return "", err
+		}
+		cfg.TLSConfig = mode
+	}
+
+	return cfg.FormatDSN(), nil
+}
+
+// register - the driver's name for the TLS mode, a verified mode is registered with the driver first
+func (t *TLSConfig) register(hostname string) (string, error) {
+	switch t.Mode {
+	case "", TLS_DISABLED:
+		return "false", nil
+	case TLS_PREFERRED, TLS_SKIP_VERIFY:
+		if t.CA != "" || t.Cert != "" {
+			return "", fmt.Errorf("tls mode %q does not verify certificates, use %q along with ca or cert", t.Mode, TLS_VERIFY)
+		}
+		return t.Mode, nil
+	case TLS_VERIFY:
+	default:
+		return "", fmt.Errorf("unsupported tls mode %q", t.Mode)
+	}
+
+	conf := &tls.Config{ServerName: orDefault(t.ServerName, hostname)}
+	if t.CA != "" {
+		pem, err := ioutil.ReadFile(t.CA)
+		if err != nil {
+			return "", fmt.Errorf("failed to read tls ca: %w", err)
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return "", fmt.Errorf("no certificates found in %s", t.CA)
+		}
+	}
+	if t.Cert != "" || t.Key != "" {
+		cert, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+		if err != nil {
+			return "", fmt.Errorf("failed to load tls client certificate: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	if err := mysql.RegisterTLSConfig(tlsConfigName, conf); err != nil {
+		return "", err
+	}
+	return tlsConfigName, nil
+}
+
+// configurePool - applies the configured pool limits, zero leaves the database/sql defaults in place
+func (c *MysqlConfig) configurePool(db *sql.DB) error {
+	if c.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime != "" {
+		lifetime, err := time.ParseDuration(c.ConnMaxLifetime)
+		if err != nil {
+			return fmt.Errorf("invalid conn_max_lifetime %q: %w", c.ConnMaxLifetime, err)
+		}
+		db.SetConnMaxLifetime(lifetime)
+	}
+	return nil
+}
+
+func orDefault(val, def string) string {
+	if val == "" {
+		return def
+	}
+	return val
+}
@@ -0,0 +1,48 @@
+package mysql
+
+import (
+	"testing"
+)
+
+func TestDSN(t *testing.T) {
+	cases := []struct {
+		name     string
+		conf     MysqlConfig
+		expected string
+	}{
+		{"defaults", MysqlConfig{Hostname: "127.0.0.1", Port: 3306, User: "zendb", Password: "secret"},
+			"zendb:secret@tcp(127.0.0.1:3306)/zendb?charset=utf8mb4"},
+		{"unix socket", MysqlConfig{Protocol: "unix", Socket: "/var/run/mysqld/mysqld.sock", User: "zendb", Database: "support"},
+			"zendb@unix(/var/run/mysqld/mysqld.sock)/support?charset=utf8mb4"},
+		{"tls", MysqlConfig{Hostname: "db", Port: 3306, User: "zendb", TLS: &TLSConfig{Mode: TLS_SKIP_VERIFY}},
+			"zendb@tcp(db:3306)/zendb?tls=skip-verify&charset=utf8mb4"},
+	}
+
+	for _, c := range cases {
+		dsn, err := c.conf.dsn()
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if dsn != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.name, c.expected, dsn)
+		}
+	}
+}
+
+func TestDSNRejectsInvalidConfiguration(t *testing.T) {
+	cases := map[string]MysqlConfig{
+		"unknown protocol":   {Protocol: "udp"},
+		"missing socket":     {Protocol: "unix"},
+		"unknown tls mode":   {TLS: &TLSConfig{Mode: "always"}},
+		"unverified ca":      {TLS: &TLSConfig{Mode: TLS_PREFERRED, CA: "ca.pem"}},
+		"missing ca":         {TLS: &TLSConfig{Mode: TLS_VERIFY, CA: "./missing-ca.pem"}},
+		"missing client key": {TLS: &TLSConfig{Mode: TLS_VERIFY, Cert: "./client.pem"}},
+	}
+
+	for name, conf := range cases {
+		if _, err := conf.dsn(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
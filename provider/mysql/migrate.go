@@ -0,0 +1,102 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rnpridgeon/zendb/provider/migration"
+)
+
+const (
+	SCHEMA_MIGRATIONS = "schema_migrations"
+
+	// held while migrating so concurrent runs do not apply the same migration twice
+	migrationLock        = "zendb_migrate"
+	migrationLockTimeout = 60
+
+	createMigrations = "CREATE TABLE IF NOT EXISTS " + SCHEMA_MIGRATIONS + " (version BIGINT UNSIGNED NOT NULL, " +
+		"name VARCHAR(255) NOT NULL, applied_at BIGINT UNSIGNED NOT NULL, PRIMARY KEY (`version`))"
+	fetchVersion    = " SELECT COALESCE(MAX(version), 0) FROM " + SCHEMA_MIGRATIONS
+	importMigration = " INSERT INTO " + SCHEMA_MIGRATIONS + "(version, name, applied_at) VALUES (?, ?, ?)"
+	deleteMigration = " DELETE FROM " + SCHEMA_MIGRATIONS + " WHERE version = ?"
+	acquireLock     = " SELECT GET_LOCK(?, ?)"
+	releaseLock     = " SELECT RELEASE_LOCK(?)"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// SchemaVersion - version of the last migration applied, zero for a database which was never migrated
+func (p *MysqlProvider) SchemaVersion(ctx context.Context) (int64, error) {
+	if _, err := p.dbClient.ExecContext(ctx, createMigrations); err != nil {
+		return 0, fmt.Errorf("SQLException: failed to create %s: %w", SCHEMA_MIGRATIONS, err)
+	}
+
+	var version int64
+	err := p.dbClient.QueryRowContext(ctx, fetchVersion).Scan(&version)
+	return version, err
+}
+
+// Migrate - applies or reverts migrations until the schema is at version, a negative version applies every migration.
+// Migrations are recorded one at a time, mysql commits DDL implicitly so a failed migration is not rolled back.
+func (p *MysqlProvider) Migrate(ctx context.Context, version int64) (int64, error) {
+	migrations, err := migration.Load(migrationFiles, "migrations")
+	if err != nil {
+		return 0, err
+	}
+
+	// GET_LOCK belongs to a session, every statement has to run on the same connection
+	c, err := p.dbClient.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+
+	var locked sql.NullInt64
+	if err = c.QueryRowContext(ctx, acquireLock, migrationLock, migrationLockTimeout).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if locked.Int64 != 1 {
+		return 0, fmt.Errorf("timed out waiting for another migration to finish")
+	}
+	defer c.ExecContext(context.Background(), releaseLock, migrationLock)
+
+	if _, err = c.ExecContext(ctx, createMigrations); err != nil {
+		return 0, fmt.Errorf("SQLException: failed to create %s: %w", SCHEMA_MIGRATIONS, err)
+	}
+
+	var current int64
+	if err = c.QueryRowContext(ctx, fetchVersion).Scan(&current); err != nil {
+		return 0, err
+	}
+
+	for _, step := range migration.Plan(migrations, current, version) {
+		for _, stmt := range migration.Statements(step.Script()) {
+			if _, err = c.ExecContext(ctx, stmt); err != nil {
+				return current, fmt.Errorf("SQLException: migration %s failed: %w", step.Migration, err)
+			}
+		}
+
+		if step.Revert {
+			_, err = c.ExecContext(ctx, deleteMigration, step.Version)
+		} else {
+			_, err = c.ExecContext(ctx, importMigration, step.Version, step.Name, time.Now().Unix())
+		}
+		if err != nil {
+			return current, err
+		}
+
+		if step.Revert {
+			log.Printf("INFO: Reverted migration %s", step.Migration)
+		} else {
+			log.Printf("INFO: Applied migration %s", step.Migration)
+		}
+		current = step.Result
+	}
+
+	return current, nil
+}
@@ -0,0 +1,26 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rnpridgeon/zendb/provider/migration"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := migration.Load(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 || migrations[0].Name != "initial" {
+		t.Fatalf("expected 0001_initial to come first, got %+v", migrations)
+	}
+
+	for _, m := range migrations {
+		for _, stmt := range migration.Statements(m.Up) {
+			if strings.HasPrefix(strings.ToUpper(stmt), "USE ") {
+				t.Errorf("migration %s must not switch databases", m)
+			}
+		}
+	}
+}
@@ -0,0 +1,2 @@
+UPDATE users SET created_at = 0 WHERE created_at IS NULL;
+ALTER TABLE users MODIFY created_at INT UNSIGNED NOT NULL;
@@ -0,0 +1,2 @@
+/* zendesk omits created_at for some users */
+ALTER TABLE users MODIFY created_at INT UNSIGNED NULL;
@@ -0,0 +1,2 @@
+/* the triggers and tables dropped were never read again, there is nothing to restore */
+DO 0;
@@ -0,0 +1,50 @@
+/* carries databases created by scripts/mysql.sql before migrations over, a no-op for every other database */
+
+/* checkpoints only move forward, enforced by the application rather than by adding one to every update */
+DROP TRIGGER IF EXISTS increment_only;
+DROP TRIGGER IF EXISTS increment_audit;
+
+/* audit values are custom field values rather than counters, ticket_audit predates audit_id where it is missing */
+SET @legacy_audit = (SELECT COUNT(*) = 0 FROM information_schema.columns
+  WHERE table_schema = DATABASE() AND table_name = 'ticket_audit' AND column_name = 'audit_id');
+SET @alter_audit = IF(@legacy_audit,
+  'ALTER TABLE ticket_audit MODIFY value VARCHAR(255), ADD COLUMN audit_id BIGINT UNSIGNED NOT NULL DEFAULT 0 AFTER ticket_id',
+  'DO 0');
+PREPARE alter_audit FROM @alter_audit;
+EXECUTE alter_audit;
+DEALLOCATE PREPARE alter_audit;
+
+/* values written while increment_audit was installed cannot be trusted, export audits again */
+DELETE FROM ticket_audit WHERE @legacy_audit;
+DELETE FROM checkpoints WHERE name = 'ticket_audit' AND @legacy_audit;
+
+/* empty stand-ins let the copies below run against databases which never had these tables */
+CREATE TABLE IF NOT EXISTS sequence_table (
+	sequence_name       VARCHAR(20) NOT NULL,
+	last_val            BIGINT UNSIGNED NOT NULL DEFAULT 0,
+	PRIMARY KEY (`sequence_name`)
+);
+
+CREATE TABLE IF NOT EXISTS cursor_table (
+	cursor_name         VARCHAR(20) NOT NULL,
+	last_cursor         VARCHAR(255) NOT NULL,
+	PRIMARY KEY (`cursor_name`)
+);
+
+/* cursors take precedence over the time based sequences they replaced, neither overwrites a checkpoint */
+INSERT IGNORE INTO checkpoints (name, kind, value, written_at)
+  SELECT cursor_name, 'cursor', last_cursor, UNIX_TIMESTAMP()
+  FROM cursor_table;
+
+/* export sequences held unix timestamps, everything else the last id seen. FROM_UNIXTIME would convert to the
+   session time zone, timestamps are counted from the epoch instead to stay in UTC */
+INSERT IGNORE INTO checkpoints (name, kind, value, written_at)
+  SELECT sequence_name,
+         IF(sequence_name LIKE '%\_export', 'timestamp', 'int'),
+         IF(sequence_name LIKE '%\_export',
+            DATE_FORMAT(TIMESTAMPADD(SECOND, last_val, '1970-01-01 00:00:00'), '%Y-%m-%dT%H:%i:%sZ'), last_val),
+         UNIX_TIMESTAMP()
+  FROM sequence_table;
+
+DROP TABLE cursor_table;
+DROP TABLE sequence_table;
@@ -0,0 +1,355 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/golang/snappy"
+)
+
+// Parquet's thrift enums, only the members written here
+const (
+	typeBoolean   = 0
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	convertedNone            = -1
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+	convertedJSON            = 19
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+
+	pageData = 0
+)
+
+// thrift compact protocol field types
+const (
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+var magic = []byte("PAR1")
+
+// compact - the subset of thrift's compact protocol parquet metadata is written in
+type compact struct {
+	buf bytes.Buffer
+	// id of the last field written to each struct being written, innermost last
+	last []int16
+}
+
+func newCompact() *compact {
+	return &compact{last: []int16{0}}
+}
+
+func (c *compact) uvarint(v uint64) {
+	var raw [binary.MaxVarintLen64]byte
+	c.buf.Write(raw[:binary.PutUvarint(raw[:], v)])
+}
+
+// varint - signed integers are zigzag encoded
+func (c *compact) varint(v int64) {
+	c.uvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+// field - headers hold the delta from the previous field's id when it is small enough
+func (c *compact) field(id int16, typ byte) {
+	top := len(c.last) - 1
+	if delta := id - c.last[top]; delta > 0 && delta <= 15 {
+		c.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		c.buf.WriteByte(typ)
+		c.varint(int64(id))
+	}
+	c.last[top] = id
+}
+
+func (c *compact) i32(id int16, v int32) {
+	c.field(id, compactI32)
+	c.varint(int64(v))
+}
+
+func (c *compact) i64(id int16, v int64) {
+	c.field(id, compactI64)
+	c.varint(v)
+}
+
+func (c *compact) binary(id int16, v string) {
+	c.field(id, compactBinary)
+	c.str(v)
+}
+
+func (c *compact) str(v string) {
+	c.uvarint(uint64(len(v)))
+	c.buf.WriteString(v)
+}
+
+// list - starts a list of n elements of typ, elements follow without field headers
+func (c *compact) list(id int16, typ byte, n int) {
+	c.field(id, compactList)
+	if n < 15 {
+		c.buf.WriteByte(byte(n)<<4 | typ)
+		return
+	}
+	c.buf.WriteByte(0xF0 | typ)
+	c.uvarint(uint64(n))
+}
+
+// begin - starts a struct, as field id or as a list element when id is zero
+func (c *compact) begin(id int16) {
+	if id != 0 {
+		c.field(id, compactStruct)
+	}
+	c.last = append(c.last, 0)
+}
+
+func (c *compact) end() {
+	c.buf.WriteByte(0)
+	c.last = c.last[:len(c.last)-1]
+}
+
+// chunk - where a column's single page landed in the file
+type chunk struct {
+	offset       int64
+	uncompressed int64
+	compressed   int64
+}
+
+// countingWriter - tracks the offset pages are written at
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// writeFile - writes rows as a parquet file holding a single row group with one PLAIN encoded page per column
+func writeFile(out io.Writer, s schema, rows []interface{}, codec int32) error {
+	w := &countingWriter{w: out}
+	if _, err := w.Write(magic); err != nil {
+		return err
+	}
+
+	chunks := make([]chunk, len(s))
+	for i, col := range s {
+		body, err := col.page(rows)
+		if err != nil {
+			return err
+		}
+		compressed, err := compress(codec, body)
+		if err != nil {
+			return err
+		}
+
+		header := newCompact()
+		header.i32(1, pageData)
+		header.i32(2, int32(len(body)))
+		header.i32(3, int32(len(compressed)))
+		header.begin(5)
+		header.i32(1, int32(len(rows)))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.end()
+		header.end()
+
+		chunks[i] = chunk{
+			offset:       w.n,
+			uncompressed: int64(header.buf.Len() + len(body)),
+			compressed:   int64(header.buf.Len() + len(compressed)),
+		}
+		if _, err = w.Write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if _, err = w.Write(compressed); err != nil {
+			return err
+		}
+	}
+
+	footer := s.footer(int64(len(rows)), chunks, codec)
+	if _, err := w.Write(footer); err != nil {
+		return err
+	}
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	if _, err := w.Write(length[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(magic)
+	return err
+}
+
+// footer - FileMetaData describing the schema and the single row group
+func (s schema) footer(numRows int64, chunks []chunk, codec int32) []byte {
+	c := newCompact()
+	c.i32(1, 1)
+
+	c.list(2, compactStruct, len(s)+1)
+	c.begin(0)
+	c.binary(4, "schema")
+	c.i32(5, int32(len(s)))
+	c.end()
+	for _, col := range s {
+		c.begin(0)
+		c.i32(1, col.physical)
+		repetition := int32(repetitionRequired)
+		if col.optional {
+			repetition = repetitionOptional
+		}
+		c.i32(3, repetition)
+		c.binary(4, col.name)
+		if col.converted != convertedNone {
+			c.i32(6, col.converted)
+		}
+		c.end()
+	}
+
+	c.i64(3, numRows)
+
+	var total int64
+	for _, ch := range chunks {
+		total += ch.uncompressed
+	}
+	c.list(4, compactStruct, 1)
+	c.begin(0)
+	c.list(1, compactStruct, len(s))
+	for i, col := range s {
+		ch := chunks[i]
+		c.begin(0)
+		c.i64(2, ch.offset)
+		c.begin(3)
+		c.i32(1, col.physical)
+		c.list(2, compactI32, 2)
+		c.varint(encodingPlain)
+		c.varint(encodingRLE)
+		c.list(3, compactBinary, 1)
+		c.str(col.name)
+		c.i32(4, codec)
+		c.i64(5, numRows)
+		c.i64(6, ch.uncompressed)
+		c.i64(7, ch.compressed)
+		c.i64(9, ch.offset)
+		c.end()
+		c.end()
+	}
+	c.i64(2, total)
+	c.i64(3, numRows)
+	c.end()
+
+	c.binary(6, "zendb")
+	c.end()
+	return c.buf.Bytes()
+}
+
+// page - definition levels of optional columns followed by the PLAIN encoded values which are present
+func (col column) page(rows []interface{}) ([]byte, error) {
+	var values bytes.Buffer
+	defined := make([]bool, len(rows))
+	var bits []bool
+
+	for i, row := range rows {
+		val, ok, err := col.value(row)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", col.name, err)
+		}
+		if !ok {
+			continue
+		}
+		defined[i] = true
+
+		switch v := val.(type) {
+		case bool:
+			bits = append(bits, v)
+		case int64:
+			binary.Write(&values, binary.LittleEndian, v)
+		case float64:
+			binary.Write(&values, binary.LittleEndian, math.Float64bits(v))
+		case string:
+			binary.Write(&values, binary.LittleEndian, uint32(len(v)))
+			values.WriteString(v)
+		}
+	}
+	if col.physical == typeBoolean {
+		values.Write(packBits(bits))
+	}
+
+	if !col.optional {
+		return values.Bytes(), nil
+	}
+
+	levels := rle(defined)
+	page := make([]byte, 4, 4+len(levels)+values.Len())
+	binary.LittleEndian.PutUint32(page, uint32(len(levels)))
+	page = append(page, levels...)
+	return append(page, values.Bytes()...), nil
+}
+
+// rle - definition levels of bit width one as runs of the RLE/bit-packing hybrid encoding
+func rle(defined []bool) []byte {
+	var out bytes.Buffer
+	var raw [binary.MaxVarintLen64]byte
+	for start := 0; start < len(defined); {
+		end := start
+		for end < len(defined) && defined[end] == defined[start] {
+			end++
+		}
+		out.Write(raw[:binary.PutUvarint(raw[:], uint64(end-start)<<1)])
+		if defined[start] {
+			out.WriteByte(1)
+		} else {
+			out.WriteByte(0)
+		}
+		start = end
+	}
+	return out.Bytes()
+}
+
+// packBits - PLAIN booleans, one bit each starting with the least significant
+func packBits(bits []bool) []byte {
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			packed[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return packed
+}
+
+func compress(codec int32, body []byte) ([]byte, error) {
+	switch codec {
+	case codecSnappy:
+		return snappy.Encode(nil, body), nil
+	case codecGzip:
+		var out bytes.Buffer
+		zw := gzip.NewWriter(&out)
+		if _, err := zw.Write(body); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	default:
+		return body, nil
+	}
+}
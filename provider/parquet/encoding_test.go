@@ -0,0 +1,163 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+// decoder - reads thrift compact structs into maps of field id to value, enough to inspect what writeFile wrote
+type decoder struct {
+	r *bytes.Reader
+}
+
+func (d decoder) varint() int64 {
+	v, _ := binary.ReadUvarint(d.r)
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (d decoder) value(typ byte) interface{} {
+	switch typ {
+	case compactI32, compactI64:
+		return d.varint()
+	case compactBinary:
+		n, _ := binary.ReadUvarint(d.r)
+		raw := make([]byte, n)
+		d.r.Read(raw)
+		return string(raw)
+	case compactList:
+		header, _ := d.r.ReadByte()
+		n := int(header >> 4)
+		if n == 15 {
+			size, _ := binary.ReadUvarint(d.r)
+			n = int(size)
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = d.value(header & 0x0F)
+		}
+		return list
+	case compactStruct:
+		return d.structure()
+	}
+	return nil
+}
+
+func (d decoder) structure() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var id int16
+	for {
+		header, err := d.r.ReadByte()
+		if err != nil || header == 0 {
+			return fields
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(d.varint())
+		}
+		fields[id] = d.value(header & 0x0F)
+	}
+}
+
+type row struct {
+	Id      int64
+	Name    string
+	Active  bool
+	Solved  time.Time
+	Details map[string]string
+}
+
+func TestWriteFile(t *testing.T) {
+	rows := []interface{}{
+		row{Id: 1, Name: "a", Active: true, Solved: time.Unix(1500000000, 0), Details: map[string]string{"k": "v"}},
+		row{Id: 2, Name: "b"},
+		row{Id: 3, Name: "c", Active: true},
+	}
+
+	for _, codec := range []int32{codecUncompressed, codecSnappy, codecGzip} {
+		var out bytes.Buffer
+		if err := writeFile(&out, schemaOf(row{}), rows, codec); err != nil {
+			t.Fatal(err)
+		}
+
+		raw := out.Bytes()
+		if !bytes.HasPrefix(raw, magic) || !bytes.HasSuffix(raw, magic) {
+			t.Fatalf("expected the file to start and end with %s", magic)
+		}
+		length := binary.LittleEndian.Uint32(raw[len(raw)-8:])
+		footer := decoder{bytes.NewReader(raw[len(raw)-8-int(length) : len(raw)-8])}.structure()
+
+		if footer[3] != int64(3) {
+			t.Errorf("expected 3 rows, got %v", footer[3])
+		}
+		elements := footer[2].([]interface{})
+		var names []string
+		for _, e := range elements[1:] {
+			names = append(names, e.(map[int16]interface{})[4].(string))
+		}
+		if len(names) != 5 || names[0] != "id" || names[3] != "solved" {
+			t.Errorf("unexpected columns %v", names)
+		}
+		if solved := elements[4].(map[int16]interface{}); solved[3] != int64(repetitionOptional) || solved[6] != int64(convertedTimestampMillis) {
+			t.Errorf("expected solved to be an optional timestamp, got %v", solved)
+		}
+
+		columns := footer[4].([]interface{})[0].(map[int16]interface{})[1].([]interface{})
+		pages := make([][]byte, len(columns))
+		for i, c := range columns {
+			meta := c.(map[int16]interface{})[3].(map[int16]interface{})
+			if meta[4] != int64(codec) {
+				t.Errorf("expected codec %d, got %v", codec, meta[4])
+			}
+
+			r := bytes.NewReader(raw[meta[9].(int64):])
+			header := decoder{r}.structure()
+			body := make([]byte, header[3].(int64))
+			r.Read(body)
+			pages[i] = decompress(t, codec, body)
+		}
+
+		if ids := pages[0]; binary.LittleEndian.Uint64(ids[8:]) != 2 {
+			t.Errorf("expected the second id to be 2, got %v", ids)
+		}
+		if active := pages[2]; active[0] != 0x05 {
+			t.Errorf("expected active to be packed as 101, got %b", active[0])
+		}
+		// levels: 4 byte length, a run of one defined value then a run of two nulls, then the single value
+		solved := pages[3]
+		if !bytes.Equal(solved[:8], []byte{4, 0, 0, 0, 2, 1, 4, 0}) || int64(binary.LittleEndian.Uint64(solved[8:])) != 1500000000000 {
+			t.Errorf("unexpected solved page %v", solved)
+		}
+		if details := pages[4]; !bytes.HasSuffix(details, []byte(`{"k":"v"}`)) {
+			t.Errorf("expected details as JSON, got %q", details)
+		}
+	}
+}
+
+func decompress(t *testing.T, codec int32, body []byte) []byte {
+	switch codec {
+	case codecSnappy:
+		raw, err := snappy.Decode(nil, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	case codecGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := ioutil.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	return body
+}
@@ -0,0 +1,102 @@
+package parquet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+// MANIFEST - file under the sink's path listing every committed file along with the checkpoints it covers
+const MANIFEST = "_manifest.jsonl"
+
+// File - a parquet file written by a single import, path is relative to the sink's path
+type File struct {
+	Target string `json:"target"`
+	Path   string `json:"path"`
+	Rows   int    `json:"rows"`
+}
+
+// Entry - one line of the manifest. Files and checkpoints of an import are committed together by the line naming
+// them, files no line names were left behind by an interrupted import and hold no committed rows.
+// A reset entry discards the named checkpoint instead.
+type Entry struct {
+	Written_at  time.Time           `json:"written_at"`
+	Files       []File              `json:"files,omitempty"`
+	Checkpoints []models.Checkpoint `json:"checkpoints,omitempty"`
+	Reset       string              `json:"reset,omitempty"`
+}
+
+// apply - folds entry into the committed checkpoints the way the sql sinks commit theirs,
+// checkpoints which would move progress backwards are skipped
+func (e Entry) apply(checkpoints map[string]models.Checkpoint) {
+	if e.Reset != "" {
+		delete(checkpoints, e.Reset)
+	}
+	for _, checkpoint := range e.Checkpoints {
+		if prev, ok := checkpoints[checkpoint.Name]; ok && !checkpoint.Advances(prev) {
+			continue
+		}
+		checkpoints[checkpoint.Name] = checkpoint
+	}
+}
+
+// readManifest - the committed checkpoints, a last line cut short by a crash is dropped from the file
+func readManifest(path string) (map[string]models.Checkpoint, error) {
+	checkpoints := make(map[string]models.Checkpoint)
+
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return checkpoints, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if complete := bytes.LastIndexByte(raw, '\n') + 1; complete < len(raw) {
+		log.Printf("WARN: Discarding incomplete last entry of %s", path)
+		if err = os.Truncate(path, int64(complete)); err != nil {
+			return nil, err
+		}
+		raw = raw[:complete]
+	}
+
+	lines := bufio.NewScanner(bytes.NewReader(raw))
+	lines.Buffer(nil, len(raw)+1)
+	for n := 1; lines.Scan(); n++ {
+		var e Entry
+		if err = json.Unmarshal(lines.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("corrupt entry on line %d of %s: %w", n, path, err)
+		}
+		e.apply(checkpoints)
+	}
+	return checkpoints, lines.Err()
+}
+
+// appendManifest - durably appends entry, once it returns the entry's files and checkpoints are committed
+func appendManifest(path string, e Entry) error {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(raw, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,359 @@
+package parquet
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+	"github.com/rnpridgeon/zendb/provider/sink"
+)
+
+// targets, named as in the mysql sink so transformations register against either. Each is a directory of files.
+const (
+	TICKET_FIELDS       = sink.TICKET_FIELDS
+	TICKET_FIELD_VALUES = sink.TICKET_FIELD_VALUES
+
+	GROUPS        = sink.GROUPS
+	ORGANIZATIONS = sink.ORGANIZATIONS
+	USERS         = sink.USERS
+	TICKETS       = sink.TICKETS
+
+	TICKET_METRICS = sink.TICKET_METRICS
+	TICKET_AUDITS  = sink.TICKET_AUDITS
+)
+
+// Compression codecs
+const (
+	SNAPPY = "snappy"
+	GZIP   = "gzip"
+	NONE   = "none"
+)
+
+// ParquetConfig - see the parquet section of the README
+type ParquetConfig struct {
+	Type string `json:"type"`
+	// directory files and the manifest are written to, created if missing
+	Path string `json:"path"`
+	// snappy (default), gzip or none
+	Compression string `json:"compression"`
+}
+
+// fieldValue - custom field values are written to their own target as the sql sinks do, the raw value as JSON
+type fieldValue struct {
+	Ticket_id         int64
+	Field_id          int64
+	Raw_value         interface{}
+	Transformed_value string
+}
+
+// ParquetProvider - writes every import as new files under <path>/<target>/date=<YYYY-MM-DD>/, partitioned by the
+// day they were written, and commits them with their checkpoints by appending to the manifest.
+// A single process may write to path at a time.
+type ParquetProvider struct {
+	path            string
+	codec           int32
+	transformations sink.Transformations
+
+	// guards the manifest and the checkpoints committed to it
+	mu          sync.Mutex
+	checkpoints map[string]models.Checkpoint
+
+	// distinguishes files written within the same instant
+	seq uint64
+	now func() time.Time
+}
+
+func Open(conf *ParquetConfig) (*ParquetProvider, error) {
+	if conf.Path == "" {
+		return nil, fmt.Errorf("the parquet sink requires a path")
+	}
+
+	var codec int32
+	switch conf.Compression {
+	case "", SNAPPY:
+		codec = codecSnappy
+	case GZIP:
+		codec = codecGzip
+	case NONE:
+		codec = codecUncompressed
+	default:
+		return nil, fmt.Errorf("unsupported compression %q, expected %s, %s or %s", conf.Compression, SNAPPY, GZIP, NONE)
+	}
+
+	if err := os.MkdirAll(conf.Path, 0755); err != nil {
+		return nil, err
+	}
+	checkpoints, err := readManifest(filepath.Join(conf.Path, MANIFEST))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ParquetProvider{
+		path:            conf.Path,
+		codec:           codec,
+		transformations: make(sink.Transformations),
+		checkpoints:     checkpoints,
+		now:             time.Now,
+	}, nil
+}
+
+func (p *ParquetProvider) RegisterTransformation(target string, fn func(interface{})) {
+	p.transformations.Register(target, fn)
+}
+
+func (p *ParquetProvider) FetchCheckpoints(ctx context.Context) (map[string]models.Checkpoint, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	checkpoints := make(map[string]models.Checkpoint, len(p.checkpoints))
+	for name, c := range p.checkpoints {
+		checkpoints[name] = c
+	}
+	return checkpoints, nil
+}
+
+// CommitCheckpoint - records checkpoint on its own, imports commit theirs alongside the files they cover
+func (p *ParquetProvider) CommitCheckpoint(ctx context.Context, checkpoint models.Checkpoint) error {
+	return p.commit(ctx, nil, []models.Checkpoint{checkpoint})
+}
+
+func (p *ParquetProvider) ResetCheckpoint(ctx context.Context, name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e := Entry{Written_at: p.now(), Reset: name}
+	if err := appendManifest(filepath.Join(p.path, MANIFEST), e); err != nil {
+		return fmt.Errorf("failed to reset %v in %s: %w", name, MANIFEST, err)
+	}
+	e.apply(p.checkpoints)
+	return nil
+}
+
+// commit - appends files and checkpoints to the manifest, stamping checkpoints with the current time unless they
+// were already written. Files are removed again should the import be cancelled or the manifest not be written.
+func (p *ParquetProvider) commit(ctx context.Context, files []File, checkpoints []models.Checkpoint) (err error) {
+	defer func() {
+		if err != nil {
+			p.discard(files)
+		}
+	}()
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	for i := range checkpoints {
+		if checkpoints[i].Written_at.IsZero() {
+			checkpoints[i].Written_at = now
+		}
+	}
+
+	e := Entry{Written_at: now, Files: files, Checkpoints: checkpoints}
+	if err = appendManifest(filepath.Join(p.path, MANIFEST), e); err != nil {
+		return fmt.Errorf("failed to commit to %s: %w", MANIFEST, err)
+	}
+	e.apply(p.checkpoints)
+	return nil
+}
+
+func (p *ParquetProvider) discard(files []File) {
+	for _, f := range files {
+		if err := os.Remove(filepath.Join(p.path, f.Path)); err != nil && !os.IsNotExist(err) {
+			log.Printf("WARN: failed to remove uncommitted %s: %s", f.Path, err)
+		}
+	}
+}
+
+// write - writes rows of model's schema to a new file of target, nothing is written for an empty page.
+// The file is only renamed into its partition once complete so readers never see a partial file.
+func (p *ParquetProvider) write(target string, model interface{}, rows []interface{}) ([]File, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	now := p.now().UTC()
+	name := fmt.Sprintf("%s-%d.parquet", now.Format("150405.000000000"), atomic.AddUint64(&p.seq, 1))
+	rel := filepath.Join(target, "date="+now.Format("2006-01-02"), name)
+	path := filepath.Join(p.path, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = writeFile(tmp, schemaOf(model), rows, p.codec); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", rel, err)
+	}
+
+	return []File{{Target: target, Path: filepath.ToSlash(rel), Rows: len(rows)}}, nil
+}
+
+// Each import writes its files, the id of the last row written and the given checkpoints in a single manifest
+// entry, a checkpoint is never committed without the rows it covers.
+
+func (p *ParquetProvider) ImportTicketFields(ctx context.Context, entities []models.Ticket_field, checkpoints ...models.Checkpoint) error {
+	rows := make([]interface{}, 0, len(entities))
+	last := p.transformations.TicketFields(entities, func(e models.Ticket_field) {
+		rows = append(rows, e)
+	})
+
+	return p.importRows(ctx, TICKET_FIELDS, models.Ticket_field{}, rows, append(checkpoints, models.IntCheckpoint(TICKET_FIELDS, last)))
+}
+
+func (p *ParquetProvider) ImportGroups(ctx context.Context, entities []models.Group, checkpoints ...models.Checkpoint) error {
+	rows, last := p.groupRows(entities)
+	return p.importRows(ctx, GROUPS, models.Group{}, rows, append(checkpoints, models.IntCheckpoint(GROUPS, last)))
+}
+
+func (p *ParquetProvider) ImportOrganizations(ctx context.Context, entities []models.Organization, checkpoints ...models.Checkpoint) error {
+	defer sink.TimeTrack(time.Now(), "Organization Import")
+
+	rows, last := p.organizationRows(entities)
+	return p.importRows(ctx, ORGANIZATIONS, models.Organization{}, rows, append(checkpoints, models.IntCheckpoint(ORGANIZATIONS, last)))
+}
+
+func (p *ParquetProvider) ImportUsers(ctx context.Context, entities []models.User, checkpoints ...models.Checkpoint) error {
+	defer sink.TimeTrack(time.Now(), "User import")
+
+	rows, last := p.userRows(entities)
+	return p.importRows(ctx, USERS, models.User{}, rows, append(checkpoints, models.IntCheckpoint(USERS, last)))
+}
+
+// ImportTickets - every sideloaded resource and the tickets' custom field values go to files of their own,
+// all of them committed by a single manifest entry
+func (p *ParquetProvider) ImportTickets(ctx context.Context, page models.Ticket_page, checkpoints ...models.Checkpoint) error {
+	defer sink.TimeTrack(time.Now(), "Ticket import")
+
+	groups, lastGroup := p.groupRows(page.Groups)
+	organizations, lastOrganization := p.organizationRows(page.Organizations)
+	users, lastUser := p.userRows(page.Users)
+	tickets, values, lastTicket := p.ticketRows(page.Tickets)
+	metrics, lastMetric := p.ticketMetricRows(page.Metric_sets)
+
+	steps := []struct {
+		target string
+		model  interface{}
+		rows   []interface{}
+		last   int64
+	}{
+		{GROUPS, models.Group{}, groups, lastGroup},
+		{ORGANIZATIONS, models.Organization{}, organizations, lastOrganization},
+		{USERS, models.User{}, users, lastUser},
+		{TICKETS, models.Ticket{}, tickets, lastTicket},
+		{TICKET_FIELD_VALUES, fieldValue{}, values, 0},
+		{TICKET_METRICS, models.Ticket_metrics{}, metrics, lastMetric},
+	}
+
+	var files []File
+	for _, step := range steps {
+		written, err := p.write(step.target, step.model, step.rows)
+		if err != nil {
+			p.discard(files)
+			return err
+		}
+		files = append(files, written...)
+		if len(step.rows) > 0 && step.target != TICKET_FIELD_VALUES {
+			checkpoints = append(checkpoints, models.IntCheckpoint(step.target, step.last))
+		}
+	}
+	return p.commit(ctx, files, checkpoints)
+}
+
+// ImportTicketFieldValues - values of a single ticket, tickets imported with their custom fields need no separate call
+func (p *ParquetProvider) ImportTicketFieldValues(ctx context.Context, parent int64, entities []models.Custom_fields) error {
+	return p.importRows(ctx, TICKET_FIELD_VALUES, fieldValue{}, p.ticketFieldValueRows(parent, entities), nil)
+}
+
+func (p *ParquetProvider) ImportTicketMetrics(ctx context.Context, entities []models.Ticket_metrics, checkpoints ...models.Checkpoint) error {
+	rows, last := p.ticketMetricRows(entities)
+	return p.importRows(ctx, TICKET_METRICS, models.Ticket_metrics{}, rows, append(checkpoints, models.IntCheckpoint(TICKET_METRICS, last)))
+}
+
+// ImportAudit - every audit is written along with all of its events, as with the sql sinks no table checkpoint
+// is written
+func (p *ParquetProvider) ImportAudit(ctx context.Context, entities []models.Audit, checkpoints ...models.Checkpoint) error {
+	defer sink.TimeTrack(time.Now(), "Audit import")
+
+	rows := make([]interface{}, 0, len(entities))
+	p.transformations.Audits(entities, func(e models.Audit) {
+		rows = append(rows, e)
+	})
+
+	return p.importRows(ctx, TICKET_AUDITS, models.Audit{}, rows, checkpoints)
+}
+
+func (p *ParquetProvider) importRows(ctx context.Context, target string, model interface{}, rows []interface{}, checkpoints []models.Checkpoint) error {
+	files, err := p.write(target, model, rows)
+	if err != nil {
+		return err
+	}
+	return p.commit(ctx, files, checkpoints)
+}
+
+func (p *ParquetProvider) groupRows(entities []models.Group) (rows []interface{}, last int64) {
+	last = p.transformations.Groups(entities, func(e models.Group) {
+		rows = append(rows, e)
+	})
+	return rows, last
+}
+
+func (p *ParquetProvider) organizationRows(entities []models.Organization) (rows []interface{}, last int64) {
+	last = p.transformations.Organizations(entities, func(e models.Organization) {
+		rows = append(rows, e)
+	})
+	return rows, last
+}
+
+func (p *ParquetProvider) userRows(entities []models.User) (rows []interface{}, last int64) {
+	last = p.transformations.Users(entities, func(e models.User) {
+		rows = append(rows, e)
+	})
+	return rows, last
+}
+
+func (p *ParquetProvider) ticketRows(entities []models.Ticket) (rows, values []interface{}, last int64) {
+	last = p.transformations.Tickets(entities, func(e models.Ticket) {
+		rows = append(rows, e)
+		for _, v := range e.Custom_fields {
+			values = append(values, fieldValue{e.Id, v.Id, v.Value, v.Transformed})
+		}
+	})
+	return rows, values, last
+}
+
+func (p *ParquetProvider) ticketFieldValueRows(parent int64, entities []models.Custom_fields) (rows []interface{}) {
+	p.transformations.FieldValues(entities, func(e models.Custom_fields) {
+		rows = append(rows, fieldValue{parent, e.Id, e.Value, e.Transformed})
+	})
+	return rows
+}
+
+// ticketMetricRows - as with the sql sinks the last ticket is the highest solved one
+func (p *ParquetProvider) ticketMetricRows(entities []models.Ticket_metrics) (rows []interface{}, last int64) {
+	last = p.transformations.TicketMetrics(entities, func(e models.Ticket_metrics) {
+		rows = append(rows, e)
+	})
+	return rows, last
+}
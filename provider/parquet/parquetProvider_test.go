@@ -0,0 +1,156 @@
+package parquet
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+func open(t *testing.T, dir string) *ParquetProvider {
+	p, err := Open(&ParquetConfig{Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.now = func() time.Time { return time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC) }
+	return p
+}
+
+// files - every parquet file under dir, relative to it
+func files(t *testing.T, dir string) []string {
+	var found []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(path, ".parquet") {
+			rel, _ := filepath.Rel(dir, path)
+			found = append(found, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func TestImportTicketsWritesPartitionsAndManifest(t *testing.T) {
+	dir := t.TempDir()
+	p := open(t, dir)
+	ctx := context.Background()
+
+	page := models.Ticket_page{
+		Tickets: []models.Ticket{{Id: 11, Subject: "broker down", Custom_fields: []models.Custom_fields{{Id: 1, Value: "3.1"}}}},
+		Users:   []models.User{{Id: 7, Name: "jane"}},
+	}
+	if err := p.ImportTickets(ctx, page, models.CursorCheckpoint("ticket_export", "abc")); err != nil {
+		t.Fatal(err)
+	}
+
+	written := files(t, dir)
+	if len(written) != 3 {
+		t.Fatalf("expected users, tickets and ticket_metadata files, got %v", written)
+	}
+	for _, f := range written {
+		if !strings.Contains(f, "/date=2020-03-01/") {
+			t.Errorf("expected %s to be partitioned by the day it was written", f)
+		}
+	}
+
+	manifest, err := ioutil.ReadFile(filepath.Join(dir, MANIFEST))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(manifest)), "\n"); len(lines) != 1 ||
+		!strings.Contains(lines[0], written[0]) || !strings.Contains(lines[0], `"abc"`) {
+		t.Errorf("expected a single entry committing every file with the checkpoint, got %s", manifest)
+	}
+
+	// a second run appends new files rather than replacing the first
+	if err = p.ImportUsers(ctx, []models.User{{Id: 8}}, models.CursorCheckpoint("user_export", "def")); err != nil {
+		t.Fatal(err)
+	}
+	if written = files(t, dir); len(written) != 4 {
+		t.Errorf("expected a fourth file, got %v", written)
+	}
+
+	checkpoints, err := open(t, dir).FetchCheckpoints(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoints["ticket_export"].Cursor() != "abc" || checkpoints["user_export"].Cursor() != "def" ||
+		checkpoints[TICKETS].Int() != 11 || checkpoints[USERS].Int() != 8 {
+		t.Errorf("expected checkpoints to be read back from the manifest, got %v", checkpoints)
+	}
+}
+
+func TestCheckpointsDoNotMoveBackwards(t *testing.T) {
+	dir := t.TempDir()
+	p := open(t, dir)
+	ctx := context.Background()
+
+	for _, id := range []int64{5, 3} {
+		if err := p.CommitCheckpoint(ctx, models.IntCheckpoint(TICKETS, id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if checkpoints, _ := open(t, dir).FetchCheckpoints(ctx); checkpoints[TICKETS].Int() != 5 {
+		t.Errorf("expected 5, got %v", checkpoints[TICKETS])
+	}
+
+	if err := p.ResetCheckpoint(ctx, TICKETS); err != nil {
+		t.Fatal(err)
+	}
+	if checkpoints, _ := open(t, dir).FetchCheckpoints(ctx); len(checkpoints) != 0 {
+		t.Errorf("expected the reset to discard the checkpoint, got %v", checkpoints)
+	}
+}
+
+func TestCancelledImportIsNotCommitted(t *testing.T) {
+	dir := t.TempDir()
+	p := open(t, dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.ImportGroups(ctx, []models.Group{{Id: 1}}, models.IntCheckpoint("groups", 1)); err != context.Canceled {
+		t.Fatalf("expected the import to be cancelled, got %v", err)
+	}
+
+	if written := files(t, dir); len(written) != 0 {
+		t.Errorf("expected the uncommitted file to be removed, got %v", written)
+	}
+	if checkpoints, _ := p.FetchCheckpoints(context.Background()); len(checkpoints) != 0 {
+		t.Errorf("expected no checkpoints, got %v", checkpoints)
+	}
+}
+
+func TestTornManifestEntryIsDropped(t *testing.T) {
+	dir := t.TempDir()
+	p := open(t, dir)
+	ctx := context.Background()
+
+	if err := p.CommitCheckpoint(ctx, models.CursorCheckpoint("user_export", "abc")); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, MANIFEST), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"written_at": "2020-03-01T12:00:00Z", "checkpoints": [{"na`)
+	f.Close()
+
+	p = open(t, dir)
+	if err = p.CommitCheckpoint(ctx, models.CursorCheckpoint("ticket_export", "def")); err != nil {
+		t.Fatal(err)
+	}
+	checkpoints, err := open(t, dir).FetchCheckpoints(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoints["user_export"].Cursor() != "abc" || checkpoints["ticket_export"].Cursor() != "def" {
+		t.Errorf("unexpected checkpoints %v", checkpoints)
+	}
+}
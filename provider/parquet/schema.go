@@ -0,0 +1,129 @@
+package parquet
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// column - a leaf of the flat schema derived from a model's exported fields
+type column struct {
+	name      string
+	physical  int32
+	converted int32
+	optional  bool
+	// path to the field, through embedded structs
+	index []int
+	// encoded value of the field in row, false when it is null
+	value func(row interface{}) (interface{}, bool, error)
+}
+
+type schema []column
+
+// schemaOf - one column per exported field of model, named as the field in lower case like the sql sinks' columns.
+// Integers, floats, booleans and strings map onto parquet types and times onto millisecond timestamps, null while
+// unset. Anything else, e.g. custom fields or audit events, is written as a JSON string.
+func schemaOf(model interface{}) schema {
+	return fieldsOf(reflect.TypeOf(model), nil)
+}
+
+func fieldsOf(t reflect.Type, parent []int) schema {
+	var s schema
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		index := append(append([]int{}, parent...), i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Type != timeType {
+			s = append(s, fieldsOf(f.Type, index)...)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		col := column{name: strings.ToLower(f.Name), converted: convertedNone, index: index}
+		switch {
+		case f.Type == timeType:
+			col.physical, col.converted, col.optional = typeInt64, convertedTimestampMillis, true
+			col.value = col.timestamp
+		case f.Type.Kind() == reflect.Ptr && f.Type.Elem() == timeType:
+			col.physical, col.converted, col.optional = typeInt64, convertedTimestampMillis, true
+			col.value = col.timestampPtr
+		case f.Type.Kind() == reflect.Bool:
+			col.physical = typeBoolean
+			col.value = col.boolean
+		case f.Type.Kind() >= reflect.Int && f.Type.Kind() <= reflect.Int64:
+			col.physical = typeInt64
+			col.value = col.integer
+		case f.Type.Kind() == reflect.Float32 || f.Type.Kind() == reflect.Float64:
+			col.physical = typeDouble
+			col.value = col.float
+		case f.Type.Kind() == reflect.String:
+			col.physical, col.converted = typeByteArray, convertedUTF8
+			col.value = col.str
+		default:
+			col.physical, col.converted, col.optional = typeByteArray, convertedJSON, true
+			col.value = col.json
+		}
+		s = append(s, col)
+	}
+	return s
+}
+
+func (col column) field(row interface{}) reflect.Value {
+	return reflect.ValueOf(row).FieldByIndex(col.index)
+}
+
+func (col column) boolean(row interface{}) (interface{}, bool, error) {
+	return col.field(row).Bool(), true, nil
+}
+
+func (col column) integer(row interface{}) (interface{}, bool, error) {
+	return col.field(row).Int(), true, nil
+}
+
+func (col column) float(row interface{}) (interface{}, bool, error) {
+	return col.field(row).Float(), true, nil
+}
+
+func (col column) str(row interface{}) (interface{}, bool, error) {
+	return col.field(row).String(), true, nil
+}
+
+func (col column) timestamp(row interface{}) (interface{}, bool, error) {
+	return millis(col.field(row).Interface().(time.Time))
+}
+
+func (col column) timestampPtr(row interface{}) (interface{}, bool, error) {
+	at := col.field(row).Interface().(*time.Time)
+	if at == nil {
+		return nil, false, nil
+	}
+	return millis(*at)
+}
+
+func millis(at time.Time) (interface{}, bool, error) {
+	if at.IsZero() {
+		return nil, false, nil
+	}
+	return at.UnixNano() / int64(time.Millisecond), true, nil
+}
+
+func (col column) json(row interface{}) (interface{}, bool, error) {
+	val := col.field(row)
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if val.IsNil() {
+			return nil, false, nil
+		}
+	}
+
+	raw, err := json.Marshal(val.Interface())
+	if err != nil {
+		return nil, false, err
+	}
+	return string(raw), true, nil
+}
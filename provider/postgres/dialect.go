@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rnpridgeon/zendb/provider/sqlsink"
+)
+
+// postgres refuses statements with more parameters than this
+const maxPlaceholders = 65535
+
+// conditions worth retrying besides connection exceptions, class 08
+var temporaryCodes = map[pq.ErrorCode]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"55P03": true, // lock_not_available
+	"53300": true, // too_many_connections
+	"57P01": true, // admin_shutdown
+}
+
+// dialect - times are stored as timestamptz, NULL where unset, and custom field values as jsonb
+type dialect struct{}
+
+func (dialect) Param(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (dialect) Quote(name string) string {
+	return `"` + name + `"`
+}
+
+func (d dialect) OnConflict(t sqlsink.Table) string {
+	return sqlsink.OnConflict(d, t)
+}
+
+func (dialect) ForUpdate() string {
+	return " FOR UPDATE"
+}
+
+func (dialect) MaxParams() int {
+	return maxPlaceholders
+}
+
+func (dialect) Time(at time.Time) interface{} {
+	return at
+}
+
+func (dialect) Unset() interface{} {
+	return nil
+}
+
+// Value - lib/pq passes strings through for the server to cast to jsonb
+func (dialect) Value(val interface{}) interface{} {
+	if val == nil {
+		return nil
+	}
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return nil
+	}
+	return string(raw)
+}
+
+// Savepoints - a failed statement aborts a postgres transaction
+func (dialect) Savepoints() bool {
+	return true
+}
+
+func (dialect) Temporary(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code.Class() == "08" || temporaryCodes[pqErr.Code]
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/rnpridgeon/zendb/provider/sqlsink"
+)
+
+func TestOnConflict(t *testing.T) {
+	values := sqlsink.Table{Name: TICKET_FIELD_VALUES, Columns: []string{"ticket_id", "field_id", "raw_value"},
+		Keys: []string{"ticket_id", "field_id"}, Updates: []string{"raw_value"}}
+	expected := `ON CONFLICT ("ticket_id", "field_id") DO UPDATE SET "raw_value" = excluded."raw_value"`
+	if got := (dialect{}).OnConflict(values); got != expected {
+		t.Errorf("expected\n\t%s\ngot\n\t%s", expected, got)
+	}
+
+	audits := sqlsink.Table{Name: TICKET_AUDITS, Columns: []string{"ticket_id", "audit_id", "value"}, Keys: []string{"ticket_id"},
+		Updates: []string{"audit_id", "value"}, Newer: "audit_id"}
+	if guarded := (dialect{}).OnConflict(audits); !strings.HasSuffix(guarded, `WHERE excluded."audit_id" > "ticket_audit"."audit_id"`) {
+		t.Errorf("expected older audits to be ignored, got %s", guarded)
+	}
+}
+
+func TestValueIsJSON(t *testing.T) {
+	if got := (dialect{}).Value([]interface{}{"a", "b"}); got != `["a","b"]` {
+		t.Errorf("expected the value as JSON, got %v", got)
+	}
+	if got := (dialect{}).Value(nil); got != nil {
+		t.Errorf("expected NULL for a missing value, got %v", got)
+	}
+}
+
+func TestTemporary(t *testing.T) {
+	for _, code := range []pq.ErrorCode{"40P01", "08006"} {
+		if !(dialect{}).Temporary(fmt.Errorf("SQLException: %w", &pq.Error{Code: code})) {
+			t.Errorf("expected %s to be temporary", code)
+		}
+	}
+	if (dialect{}).Temporary(&pq.Error{Code: "23503"}) {
+		t.Error("expected a foreign key violation not to be temporary")
+	}
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDatabase = "zendb"
+	defaultSSLMode  = "disable"
+)
+
+// dsn - the libpq connection string described by the configuration
+func (c *PostgresConfig) dsn() (string, error) {
+	switch c.SSLMode {
+	case "", "disable", "require", "verify-ca", "verify-full":
+	default:
+		return "", fmt.Errorf("unsupported sslmode %q", c.SSLMode)
+	}
+
+	params := [][2]string{
+		{"host", c.Hostname},
+		{"user", c.User},
+		{"password", c.Password},
+		{"dbname", orDefault(c.Database, defaultDatabase)},
+		{"sslmode", orDefault(c.SSLMode, defaultSSLMode)},
+		{"sslrootcert", c.SSLRootCert},
+		{"sslcert", c.SSLCert},
+		{"sslkey", c.SSLKey},
+	}
+	if c.Port != 0 {
+		params = append(params, [2]string{"port", fmt.Sprint(c.Port)})
+	}
+
+	var pairs []string
+	for _, param := range params {
+		if param[1] != "" {
+			pairs = append(pairs, param[0]+"="+quote(param[1]))
+		}
+	}
+	return strings.Join(pairs, " "), nil
+}
+
+// quote - escapes a connection string value, see https://www.postgresql.org/docs/current/libpq-connect.html
+func quote(val string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(val) + "'"
+}
+
+// configurePool - applies the configured pool limits, zero leaves the database/sql defaults in place
+func (c *PostgresConfig) configurePool(db *sql.DB) error {
+	if c.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime != "" {
+		lifetime, err := time.ParseDuration(c.ConnMaxLifetime)
+		if err != nil {
+			return fmt.Errorf("invalid conn_max_lifetime %q: %w", c.ConnMaxLifetime, err)
+		}
+		db.SetConnMaxLifetime(lifetime)
+	}
+	return nil
+}
+
+func orDefault(val, def string) string {
+	if val == "" {
+		return def
+	}
+	return val
+}
@@ -0,0 +1,20 @@
+package postgres
+
+import (
+	"testing"
+)
+
+func TestDSN(t *testing.T) {
+	conf := PostgresConfig{Hostname: "db", Port: 5432, User: "zendb", Password: `it's\secret`,
+		SSLMode: "verify-full", SSLRootCert: "/etc/ssl/ca.pem"}
+
+	expected := `host='db' user='zendb' password='it\'s\\secret' dbname='zendb' sslmode='verify-full' ` +
+		`sslrootcert='/etc/ssl/ca.pem' port='5432'`
+	if dsn, err := conf.dsn(); err != nil || dsn != expected {
+		t.Errorf("expected %s, got %s: %v", expected, dsn, err)
+	}
+
+	if _, err := (&PostgresConfig{SSLMode: "prefer"}).dsn(); err == nil {
+		t.Error("expected an unsupported sslmode to fail")
+	}
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+
+	"github.com/rnpridgeon/zendb/provider/migration"
+)
+
+const (
+	SCHEMA_MIGRATIONS = "schema_migrations"
+
+	// advisory lock held while migrating so concurrent runs do not apply the same migration twice
+	migrationLock = 7261646
+
+	createMigrations = "CREATE TABLE IF NOT EXISTS " + SCHEMA_MIGRATIONS + " (version BIGINT NOT NULL, " +
+		"name VARCHAR(255) NOT NULL, applied_at TIMESTAMPTZ NOT NULL DEFAULT now(), PRIMARY KEY (version))"
+	fetchVersion    = " SELECT COALESCE(MAX(version), 0) FROM " + SCHEMA_MIGRATIONS
+	importMigration = " INSERT INTO " + SCHEMA_MIGRATIONS + "(version, name) VALUES ($1, $2)"
+	deleteMigration = " DELETE FROM " + SCHEMA_MIGRATIONS + " WHERE version = $1"
+	acquireLock     = " SELECT pg_advisory_xact_lock($1)"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// SchemaVersion - version of the last migration applied, zero for a database which was never migrated
+func (p *PostgresProvider) SchemaVersion(ctx context.Context) (int64, error) {
+	if _, err := p.dbClient.ExecContext(ctx, createMigrations); err != nil {
+		return 0, fmt.Errorf("SQLException: failed to create %s: %w", SCHEMA_MIGRATIONS, err)
+	}
+
+	var version int64
+	err := p.dbClient.QueryRowContext(ctx, fetchVersion).Scan(&version)
+	return version, err
+}
+
+// Migrate - applies or reverts migrations until the schema is at version, a negative version applies every migration.
+// Postgres DDL is transactional, each migration commits along with its schema_migrations row or not at all.
+func (p *PostgresProvider) Migrate(ctx context.Context, version int64) (int64, error) {
+	migrations, err := migration.Load(migrationFiles, "migrations")
+	if err != nil {
+		return 0, err
+	}
+
+	current, err := p.SchemaVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		steps := migration.Plan(migrations, current, version)
+		if len(steps) == 0 {
+			return current, nil
+		}
+		step := steps[0]
+
+		// another run may have moved the schema while this one waited for the lock, the step is planned again if so
+		applied := false
+		err = p.Atomically(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, acquireLock, migrationLock); err != nil {
+				return err
+			}
+
+			var locked int64
+			if err := tx.QueryRowContext(ctx, fetchVersion).Scan(&locked); err != nil {
+				return err
+			}
+			if locked != current {
+				current = locked
+				return nil
+			}
+
+			// lib/pq runs a script holding several statements when it is sent without arguments
+			if _, err := tx.ExecContext(ctx, step.Script()); err != nil {
+				return fmt.Errorf("SQLException: migration %s failed: %w", step.Migration, err)
+			}
+
+			var err error
+			if step.Revert {
+				_, err = tx.ExecContext(ctx, deleteMigration, step.Version)
+			} else {
+				_, err = tx.ExecContext(ctx, importMigration, step.Version, step.Name)
+			}
+			applied = err == nil
+			return err
+		})
+		if err != nil {
+			return current, err
+		}
+		if !applied {
+			continue
+		}
+
+		if step.Revert {
+			log.Printf("INFO: Reverted migration %s", step.Migration)
+		} else {
+			log.Printf("INFO: Applied migration %s", step.Migration)
+		}
+		current = step.Result
+	}
+}
@@ -0,0 +1,17 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/rnpridgeon/zendb/provider/migration"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := migration.Load(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 || migrations[0].Name != "initial" {
+		t.Fatalf("expected 0001_initial to come first, got %+v", migrations)
+	}
+}
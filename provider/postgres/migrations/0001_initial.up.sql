@@ -0,0 +1,106 @@
+/* typed progress markers, value is encoded according to kind: int, timestamp (RFC3339), cursor or json */
+CREATE TABLE IF NOT EXISTS checkpoints (
+	name        VARCHAR(64) NOT NULL,
+	kind        VARCHAR(16) NOT NULL,
+	value       TEXT NOT NULL,
+	written_at  TIMESTAMPTZ NOT NULL,
+	run_id      VARCHAR(64) NOT NULL DEFAULT '',
+	PRIMARY KEY (name)
+);
+
+CREATE TABLE IF NOT EXISTS ticket_fields (
+	id          BIGINT NOT NULL,
+	title       VARCHAR(255) NOT NULL,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS ticket_metrics (
+	id          BIGINT NOT NULL,
+	created_at  TIMESTAMPTZ,
+	updated_at  TIMESTAMPTZ,
+	ticket_id   BIGINT NOT NULL,
+	replies     BIGINT,
+	ttfr        BIGINT,
+	solved_at   TIMESTAMPTZ,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS groups (
+	id          BIGINT NOT NULL,
+	name        VARCHAR(255) NOT NULL,
+	created_at  TIMESTAMPTZ NOT NULL,
+	updated_at  TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (id)
+);
+
+/* group id is not mandatory for organizations, nor organization id for users */
+INSERT INTO groups VALUES (0, 'UNDEFINED', 'epoch', 'epoch') ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS organizations (
+	id          BIGINT NOT NULL,
+	name        VARCHAR(255) NOT NULL,
+	created_at  TIMESTAMPTZ NOT NULL,
+	updated_at  TIMESTAMPTZ NOT NULL,
+	group_id    BIGINT NOT NULL REFERENCES groups (id),
+	PRIMARY KEY (id)
+);
+
+INSERT INTO organizations VALUES (0, 'UNDEFINED', 'epoch', 'epoch', 0) ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS users (
+	id                BIGINT NOT NULL,
+	email             VARCHAR(255) NOT NULL,
+	name              VARCHAR(255) NOT NULL,
+	created_at        TIMESTAMPTZ,
+	organization_id   BIGINT DEFAULT 0 REFERENCES organizations (id),
+	default_group_id  BIGINT NOT NULL REFERENCES groups (id),
+	role              VARCHAR(10) NOT NULL,
+	time_zone         VARCHAR(64) NOT NULL,
+	updated_at        TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS tickets (
+	id               BIGINT NOT NULL,
+	subject          VARCHAR(255) NOT NULL,
+	status           VARCHAR(10) NOT NULL,
+	requester_id     BIGINT NOT NULL REFERENCES users (id),
+	submitter_id     BIGINT NOT NULL REFERENCES users (id),
+	assignee_id      BIGINT NOT NULL REFERENCES users (id),
+	organization_id  BIGINT DEFAULT 0 REFERENCES organizations (id),
+	group_id         BIGINT NOT NULL REFERENCES groups (id),
+	created_at       TIMESTAMPTZ NOT NULL,
+	updated_at       TIMESTAMPTZ NOT NULL,
+	version          VARCHAR(55) DEFAULT '-',
+	component        VARCHAR(55) DEFAULT '-',
+	priority         VARCHAR(10) DEFAULT 'undefined',
+	ttfr             BIGINT,
+	solved_at        TIMESTAMPTZ,
+	PRIMARY KEY (id)
+);
+
+/* custom field values as zendesk sent them, a value may be a string, number, boolean or list */
+CREATE TABLE IF NOT EXISTS ticket_metadata (
+	ticket_id          BIGINT NOT NULL,
+	field_id           BIGINT NOT NULL REFERENCES ticket_fields (id),
+	raw_value          JSONB,
+	transformed_value  VARCHAR(255),
+	PRIMARY KEY (ticket_id, field_id)
+);
+
+/* latest value of the audited custom field per ticket, audit_id orders competing updates */
+CREATE TABLE IF NOT EXISTS ticket_audit (
+	ticket_id  BIGINT NOT NULL REFERENCES tickets (id),
+	audit_id   BIGINT NOT NULL DEFAULT 0,
+	author_id  BIGINT NOT NULL REFERENCES users (id),
+	value      VARCHAR(255),
+	PRIMARY KEY (ticket_id)
+);
+
+/* convenience view */
+CREATE OR REPLACE VIEW ticket_view AS SELECT tickets.id, tickets.priority, organizations.name AS organization,
+                                        users.name AS requester, tickets.status, tickets.component, tickets.version,
+                                        tickets.created_at, tickets.solved_at
+                                      FROM tickets
+                                        JOIN organizations ON tickets.organization_id = organizations.id
+                                        JOIN users ON tickets.requester_id = users.id;
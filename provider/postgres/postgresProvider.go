@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+	"github.com/rnpridgeon/zendb/provider/sink"
+	"github.com/rnpridgeon/zendb/provider/sqlsink"
+)
+
+// targets, named as in the mysql sink so transformations register against either
+const (
+	CHECKPOINTS = sqlsink.CHECKPOINTS
+
+	TICKET_FIELDS       = sink.TICKET_FIELDS
+	TICKET_FIELD_VALUES = sink.TICKET_FIELD_VALUES
+
+	GROUPS        = sink.GROUPS
+	ORGANIZATIONS = sink.ORGANIZATIONS
+	USERS         = sink.USERS
+	TICKETS       = sink.TICKETS
+
+	TICKET_METRICS = sink.TICKET_METRICS
+	TICKET_AUDITS  = sink.TICKET_AUDITS
+)
+
+// DIALECT - raw queries handed to ExecRaw are written for postgres
+const DIALECT = "postgres"
+
+// PostgresConfig - see the postgres section of the README
+type PostgresConfig struct {
+	Type     string `json:"type"`
+	Hostname string `json:"hostname"`
+	Port     uint   `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	// defaults to zendb
+	Database string `json:"database"`
+	// disable (default), require, verify-ca or verify-full, certificates are paths to PEM files
+	SSLMode     string `json:"sslmode"`
+	SSLRootCert string `json:"sslrootcert"`
+	SSLCert     string `json:"sslcert"`
+	SSLKey      string `json:"sslkey"`
+	// pool limits, zero keeps the database/sql default. ConnMaxLifetime is a duration such as "5m"
+	MaxOpenConns    int    `json:"max_open_conns"`
+	MaxIdleConns    int    `json:"max_idle_conns"`
+	ConnMaxLifetime string `json:"conn_max_lifetime"`
+	// rows written per INSERT statement, defaults to 500
+	BatchSize int `json:"batch_size"`
+}
+
+// PostgresProvider - imports, updates and checkpoints are those of the shared sql sink written in postgres' dialect
+type PostgresProvider struct {
+	*sqlsink.Provider
+	dbClient *sql.DB
+}
+
+func Open(conf *PostgresConfig) (*PostgresProvider, error) {
+	dsn, err := conf.dsn()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err = conf.configurePool(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PostgresProvider{sqlsink.New(db, dialect{}, conf.BatchSize), db}, nil
+}
+
+func (p *PostgresProvider) Dialect() string {
+	return DIALECT
+}
@@ -0,0 +1,203 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// default region, MinIO and most S3-compatible stores accept it whatever they are configured with
+const DEFAULT_REGION = "us-east-1"
+
+// S3Config - see the archive section of the README
+type S3Config struct {
+	// scheme and host of the store, e.g. http://127.0.0.1:9000 or https://s3.eu-west-1.amazonaws.com
+	Endpoint   string `json:"endpoint"`
+	Region     string `json:"region"`
+	Bucket     string `json:"bucket"`
+	Access_key string `json:"access_key"`
+	Secret_key string `json:"secret_key"`
+	// prepended to every key, e.g. zendesk/
+	Prefix string `json:"prefix"`
+	// address the bucket as <bucket>.<endpoint host> rather than <endpoint>/<bucket>, MinIO expects the latter
+	Virtual_hosted bool `json:"virtual_hosted"`
+}
+
+// Bucket - an S3-compatible bucket written with signature version 4 requests, safe for concurrent use
+type Bucket struct {
+	client    *http.Client
+	base      *url.URL
+	region    string
+	prefix    string
+	accessKey string
+	secretKey string
+
+	now func() time.Time
+}
+
+// S3Error - the store rejected a request
+type S3Error struct {
+	Key        string
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *S3Error) Error() string {
+	return fmt.Sprintf("s3 request for %s failed with %d: %s %s", e.Key, e.StatusCode, e.Code, e.Message)
+}
+
+// Temporary - throttling and server side failures are worth retrying
+func (e *S3Error) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+func Open(client *http.Client, conf *S3Config) (*Bucket, error) {
+	if conf.Endpoint == "" || conf.Bucket == "" {
+		return nil, fmt.Errorf("s3 requires an endpoint and a bucket")
+	}
+	base, err := url.Parse(conf.Endpoint)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q, expected scheme://host", conf.Endpoint)
+	}
+
+	base.Path, base.RawPath = "", ""
+	if conf.Virtual_hosted {
+		base.Host = conf.Bucket + "." + base.Host
+	} else {
+		base.Path = "/" + conf.Bucket
+	}
+
+	region := conf.Region
+	if region == "" {
+		region = DEFAULT_REGION
+	}
+
+	return &Bucket{
+		client:    client,
+		base:      base,
+		region:    region,
+		prefix:    conf.Prefix,
+		accessKey: conf.Access_key,
+		secretKey: conf.Secret_key,
+		now:       time.Now,
+	}, nil
+}
+
+// Put - stores body under key, replacing any object already there
+func (b *Bucket) Put(ctx context.Context, key string, body []byte) error {
+	req, err := b.newRequest(ctx, "PUT", key, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType(key))
+
+	_, err = b.do(req, key, body)
+	return err
+}
+
+// Get - the object stored under key
+func (b *Bucket) Get(ctx context.Context, key string) ([]byte, error) {
+	req, err := b.newRequest(ctx, "GET", key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return b.do(req, key, nil)
+}
+
+// listing - the part of a ListObjectsV2 response List needs
+type listing struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	Truncated bool   `xml:"IsTruncated"`
+	Next      string `xml:"NextContinuationToken"`
+}
+
+// List - keys starting with prefix in lexical order, both relative to the configured prefix
+func (b *Bucket) List(ctx context.Context, prefix string) ([]string, error) {
+	target := *b.base
+	if target.Path == "" {
+		target.Path = "/"
+	}
+
+	var keys []string
+	params := map[string]string{"list-type": "2", "prefix": b.prefix + prefix}
+	for {
+		target.RawQuery = query(params)
+		req, err := http.NewRequest("GET", target.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err := b.do(req.WithContext(ctx), prefix, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page listing
+		if err = xml.Unmarshal(raw, &page); err != nil {
+			return nil, fmt.Errorf("failed to decode listing of %s: %w", prefix, err)
+		}
+
+		for _, c := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(c.Key, b.prefix))
+		}
+		if !page.Truncated || page.Next == "" {
+			break
+		}
+		params["continuation-token"] = page.Next
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// newRequest - request for key relative to the prefix, signed by do
+func (b *Bucket) newRequest(ctx context.Context, method, key string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, b.base.String()+"/"+escape(b.prefix+key, false), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return req.WithContext(ctx), nil
+}
+
+func (b *Bucket) do(req *http.Request, key string, body []byte) ([]byte, error) {
+	req.Header.Set("X-Amz-Content-Sha256", sha256Hex(body))
+	sign(req, b.region, b.accessKey, b.secretKey, b.now())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		s3Err := &S3Error{Key: key, StatusCode: resp.StatusCode}
+		if xml.Unmarshal(raw, s3Err) != nil {
+			s3Err.Message = resp.Status
+		}
+		return nil, s3Err
+	}
+	return raw, nil
+}
+
+func contentType(key string) string {
+	switch {
+	case strings.HasSuffix(key, ".gz"):
+		return "application/gzip"
+	case strings.HasSuffix(key, ".json"):
+		return "application/json"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,144 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stand-in - an in memory S3-compatible store, rejects requests without a signature
+type standIn struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !strings.HasPrefix(req.Header.Get("Authorization"), algorithm+" Credential=minio/") ||
+		req.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied.</Message></Error>`))
+		return
+	}
+
+	body, _ := ioutil.ReadAll(req.Body)
+	if req.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case req.Method == "PUT":
+		s.objects[req.URL.Path] = body
+		s.types[req.URL.Path] = req.Header.Get("Content-Type")
+	case req.URL.Query().Get("list-type") == "2":
+		s.list(w, req)
+	default:
+		object, ok := s.objects[req.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code></Error>`))
+			return
+		}
+		w.Write(object)
+	}
+}
+
+// list - one key per page, so the client has to follow continuation tokens
+func (s *standIn) list(w http.ResponseWriter, req *http.Request) {
+	bucket := req.URL.Path + "/"
+	var keys []string
+	for path := range s.objects {
+		key := strings.TrimPrefix(path, bucket)
+		if strings.HasPrefix(key, req.URL.Query().Get("prefix")) && key > req.URL.Query().Get("continuation-token") {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		w.Write([]byte(`<ListBucketResult><IsTruncated>false</IsTruncated></ListBucketResult>`))
+		return
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(w, `<ListBucketResult><IsTruncated>%t</IsTruncated><Contents><Key>%s</Key></Contents>`+
+		`<NextContinuationToken>%s</NextContinuationToken></ListBucketResult>`, len(keys) > 1, keys[0], keys[0])
+}
+
+func TestPut(t *testing.T) {
+	store := &standIn{objects: make(map[string][]byte), types: make(map[string]string)}
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	b, err := Open(srv.Client(), &S3Config{Endpoint: srv.URL, Bucket: "zendb", Prefix: "raw/", Access_key: "minio", Secret_key: "minio123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Put(context.Background(), "tickets/000001_a+b.json.gz", []byte("page")); err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/zendb/raw/tickets/000001_a+b.json.gz"
+	if string(store.objects[path]) != "page" || store.types[path] != "application/gzip" {
+		t.Errorf("expected the page at %s, got %v", path, store.objects)
+	}
+
+	b.accessKey = "intruder"
+	err = b.Put(context.Background(), "tickets/000002.json.gz", []byte("page"))
+	if s3Err, ok := err.(*S3Error); !ok || s3Err.Code != "AccessDenied" || s3Err.Temporary() {
+		t.Errorf("expected access to be denied for good, got %v", err)
+	}
+}
+
+func TestListAndGet(t *testing.T) {
+	store := &standIn{objects: make(map[string][]byte), types: make(map[string]string)}
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	b, err := Open(srv.Client(), &S3Config{Endpoint: srv.URL, Bucket: "zendb", Prefix: "raw/", Access_key: "minio", Secret_key: "minio123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, key := range []string{"users/000001.json.gz", "tickets/000002_c%2F2.json.gz", "tickets/000001_c1.json.gz"} {
+		if err = b.Put(ctx, key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := b.List(ctx, "tickets/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != "tickets/000001_c1.json.gz" || keys[1] != "tickets/000002_c%2F2.json.gz" {
+		t.Errorf("expected both ticket pages relative to the prefix, got %v", keys)
+	}
+
+	if body, err := b.Get(ctx, keys[1]); err != nil || string(body) != keys[1] {
+		t.Errorf("expected %s, got %s %v", keys[1], body, err)
+	}
+	if _, err = b.Get(ctx, "tickets/missing.json.gz"); err == nil {
+		t.Error("expected a missing key to fail")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	b, err := Open(http.DefaultClient, &S3Config{Endpoint: "https://s3.eu-west-1.amazonaws.com", Bucket: "zendb", Region: "eu-west-1", Virtual_hosted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.base.String() != "https://zendb.s3.eu-west-1.amazonaws.com" || b.region != "eu-west-1" {
+		t.Errorf("unexpected bucket %s in %s", b.base, b.region)
+	}
+
+	if _, err = Open(http.DefaultClient, &S3Config{Endpoint: "127.0.0.1:9000", Bucket: "zendb"}); err == nil {
+		t.Error("expected an endpoint without a scheme to be rejected")
+	}
+}
@@ -0,0 +1,159 @@
+package sink
+
+import (
+	"log"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+// targets, transformations register against these names whichever sink they are given to
+const (
+	TICKET_FIELDS       = "ticket_fields"
+	TICKET_FIELD_VALUES = "ticket_metadata"
+
+	GROUPS        = "groups"
+	ORGANIZATIONS = "organizations"
+	USERS         = "users"
+	TICKETS       = "tickets"
+
+	TICKET_METRICS = "ticket_metrics"
+	TICKET_AUDITS  = "ticket_audit"
+)
+
+func TimeTrack(start time.Time, name string) {
+	elapsed := time.Since(start)
+	log.Printf("INFO: %s took %s", name, elapsed)
+}
+
+// Transformations - functions registered against a target, every entity of the target passes through them in the
+// order they were registered before it is written. Each helper below hands the transformed entities to row and
+// returns the id the target's checkpoint advances to.
+type Transformations map[string][]func(interface{})
+
+func (t Transformations) Register(target string, fn func(interface{})) {
+	t[target] = append(t[target], fn)
+}
+
+func (t Transformations) TicketFields(entities []models.Ticket_field, row func(models.Ticket_field)) (last int64) {
+	for _, e := range entities {
+
+		for _, f := range t[TICKET_FIELDS] {
+			f(&e)
+		}
+
+		row(e)
+		if e.Id > last {
+			last = e.Id
+		}
+	}
+	return last
+}
+
+func (t Transformations) Groups(entities []models.Group, row func(models.Group)) (last int64) {
+	for _, e := range entities {
+
+		for _, f := range t[GROUPS] {
+			f(&e)
+		}
+
+		row(e)
+		if e.Id > last {
+			last = e.Id
+		}
+	}
+	return last
+}
+
+func (t Transformations) Organizations(entities []models.Organization, row func(models.Organization)) (last int64) {
+	for _, e := range entities {
+
+		for _, f := range t[ORGANIZATIONS] {
+			f(&e)
+		}
+
+		row(e)
+		if e.Id > last {
+			last = e.Id
+		}
+	}
+	return last
+}
+
+func (t Transformations) Users(entities []models.User, row func(models.User)) (last int64) {
+	for _, e := range entities {
+
+		for _, f := range t[USERS] {
+			f(&e)
+		}
+
+		row(e)
+		if e.Id > last {
+			last = e.Id
+		}
+	}
+	return last
+}
+
+// Tickets - custom field values belong to the ticket, they are transformed after it and handed over in its
+// Custom_fields. The page's own values are left untouched.
+func (t Transformations) Tickets(entities []models.Ticket, row func(models.Ticket)) (last int64) {
+	for _, e := range entities {
+
+		for _, f := range t[TICKETS] {
+			f(&e)
+		}
+
+		values := make([]models.Custom_fields, 0, len(e.Custom_fields))
+		t.FieldValues(e.Custom_fields, func(v models.Custom_fields) {
+			values = append(values, v)
+		})
+		e.Custom_fields = values
+
+		row(e)
+		if e.Id > last {
+			last = e.Id
+		}
+	}
+	return last
+}
+
+func (t Transformations) FieldValues(entities []models.Custom_fields, row func(models.Custom_fields)) {
+	for _, e := range entities {
+
+		for _, f := range t[TICKET_FIELD_VALUES] {
+			f(&e)
+		}
+
+		row(e)
+	}
+}
+
+// TicketMetrics - the last ticket is the highest solved one
+func (t Transformations) TicketMetrics(entities []models.Ticket_metrics, row func(models.Ticket_metrics)) (last int64) {
+	for _, e := range entities {
+
+		for _, f := range t[TICKET_METRICS] {
+			f(&e)
+		}
+
+		row(e)
+		// TODO: this actually short changes us
+		if e.Ticket_id > last && e.Solved_at.Unix() > 0 {
+			last = e.Ticket_id
+		}
+	}
+	return last
+}
+
+// Audits - audits carry no checkpoint of their own, the pipeline tracks them by id
+func (t Transformations) Audits(entities []models.Audit, row func(models.Audit)) {
+	for _, e := range entities {
+
+		for _, f := range t[TICKET_AUDITS] {
+			f(&e)
+		}
+
+		row(e)
+	}
+}
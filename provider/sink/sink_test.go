@@ -0,0 +1,43 @@
+package sink
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+func TestTicketsTransformCustomFields(t *testing.T) {
+	transformations := make(Transformations)
+	transformations.Register(TICKETS, func(obj interface{}) {
+		obj.(*models.Ticket).Subject = "transformed"
+	})
+	transformations.Register(TICKET_FIELD_VALUES, func(obj interface{}) {
+		obj.(*models.Custom_fields).Transformed = "3.x"
+	})
+
+	page := []models.Ticket{
+		{Id: 2, Custom_fields: []models.Custom_fields{{Id: 1, Value: "3.1"}}},
+		{Id: 5},
+	}
+	var rows []models.Ticket
+	last := transformations.Tickets(page, func(e models.Ticket) { rows = append(rows, e) })
+
+	if last != 5 || len(rows) != 2 {
+		t.Fatalf("expected both tickets up to 5, got %d rows up to %d", len(rows), last)
+	}
+	if rows[0].Subject != "transformed" || rows[0].Custom_fields[0].Transformed != "3.x" {
+		t.Errorf("expected the ticket and its custom fields to be transformed, got %+v", rows[0])
+	}
+	if page[0].Custom_fields[0].Transformed != "" {
+		t.Error("expected the page's own custom fields to be left untouched")
+	}
+}
+
+func TestTicketMetricsAdvanceToSolvedTickets(t *testing.T) {
+	solved := models.Ticket_metrics{Ticket_id: 3, Solved_at: time.Unix(1500000000, 0)}
+	last := make(Transformations).TicketMetrics([]models.Ticket_metrics{solved, {Ticket_id: 9}}, func(models.Ticket_metrics) {})
+	if last != 3 {
+		t.Errorf("expected the highest solved ticket, got %d", last)
+	}
+}
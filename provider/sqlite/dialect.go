@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/rnpridgeon/zendb/provider/sqlsink"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqlite refuses statements with more variables than this
+const maxPlaceholders = 32766
+
+// dialect - times are stored as unix seconds as in mysql, zero where unset, and custom field values as JSON text
+type dialect struct{}
+
+func (dialect) Param(n int) string {
+	return "?"
+}
+
+func (dialect) Quote(name string) string {
+	return `"` + name + `"`
+}
+
+func (d dialect) OnConflict(t sqlsink.Table) string {
+	return sqlsink.OnConflict(d, t)
+}
+
+// ForUpdate - transactions already hold the write lock, no row locking is needed
+func (dialect) ForUpdate() string {
+	return ""
+}
+
+func (dialect) MaxParams() int {
+	return maxPlaceholders
+}
+
+func (dialect) Time(at time.Time) interface{} {
+	return at.Unix()
+}
+
+func (dialect) Unset() interface{} {
+	return int64(0)
+}
+
+// Value - encoded as JSON for sqlite's json functions
+func (dialect) Value(val interface{}) interface{} {
+	if val == nil {
+		return nil
+	}
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return nil
+	}
+	return string(raw)
+}
+
+// Savepoints - sqlite rolls back only the failed statement, the rest of the transaction survives
+func (dialect) Savepoints() bool {
+	return false
+}
+
+// Temporary - the database stayed busy or locked by another process beyond the busy timeout
+func (dialect) Temporary(err error) bool {
+	var coded interface {
+		Code() int
+	}
+	if !errors.As(err, &coded) {
+		return false
+	}
+	// extended result codes keep the primary one in their low byte
+	switch coded.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return true
+	}
+	return false
+}
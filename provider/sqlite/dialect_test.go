@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rnpridgeon/zendb/provider/sqlsink"
+)
+
+func TestOnConflict(t *testing.T) {
+	audits := sqlsink.Table{Name: TICKET_AUDITS, Columns: []string{"ticket_id", "audit_id", "value"}, Keys: []string{"ticket_id"},
+		Updates: []string{"audit_id", "value"}, Newer: "audit_id"}
+	guarded := (dialect{}).OnConflict(audits)
+	if !strings.HasPrefix(guarded, `ON CONFLICT ("ticket_id") DO UPDATE SET "audit_id" = excluded."audit_id"`) ||
+		!strings.HasSuffix(guarded, `WHERE excluded."audit_id" > "ticket_audit"."audit_id"`) {
+		t.Errorf("expected older audits to be ignored, got %s", guarded)
+	}
+}
+
+// codedError - an error carrying a sqlite result code as the driver's errors do
+type codedError int
+
+func (e codedError) Error() string {
+	return "sqlite error"
+}
+
+func (e codedError) Code() int {
+	return int(e)
+}
+
+func TestTemporary(t *testing.T) {
+	// SQLITE_BUSY and its extended SQLITE_BUSY_SNAPSHOT
+	for _, code := range []codedError{5, 517} {
+		if !(dialect{}).Temporary(fmt.Errorf("SQLException: %w", code)) {
+			t.Errorf("expected %d to be temporary", code)
+		}
+	}
+	// SQLITE_CONSTRAINT_FOREIGNKEY
+	if (dialect{}).Temporary(codedError(787)) {
+		t.Error("expected a foreign key violation not to be temporary")
+	}
+}
@@ -0,0 +1,99 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rnpridgeon/zendb/provider/migration"
+)
+
+const (
+	SCHEMA_MIGRATIONS = "schema_migrations"
+
+	createMigrations = "CREATE TABLE IF NOT EXISTS " + SCHEMA_MIGRATIONS + " (version INTEGER NOT NULL, " +
+		"name TEXT NOT NULL, applied_at INTEGER NOT NULL, PRIMARY KEY (version))"
+	fetchVersion    = " SELECT COALESCE(MAX(version), 0) FROM " + SCHEMA_MIGRATIONS
+	importMigration = " INSERT INTO " + SCHEMA_MIGRATIONS + "(version, name, applied_at) VALUES (?, ?, ?)"
+	deleteMigration = " DELETE FROM " + SCHEMA_MIGRATIONS + " WHERE version = ?"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// SchemaVersion - version of the last migration applied, zero for a database which was never migrated
+func (p *SqliteProvider) SchemaVersion(ctx context.Context) (int64, error) {
+	if _, err := p.dbClient.ExecContext(ctx, createMigrations); err != nil {
+		return 0, fmt.Errorf("SQLException: failed to create %s: %w", SCHEMA_MIGRATIONS, err)
+	}
+
+	var version int64
+	err := p.dbClient.QueryRowContext(ctx, fetchVersion).Scan(&version)
+	return version, err
+}
+
+// Migrate - applies or reverts migrations until the schema is at version, a negative version applies every migration.
+// Sqlite DDL is transactional, each migration commits along with its schema_migrations row or not at all.
+func (p *SqliteProvider) Migrate(ctx context.Context, version int64) (int64, error) {
+	migrations, err := migration.Load(migrationFiles, "migrations")
+	if err != nil {
+		return 0, err
+	}
+
+	current, err := p.SchemaVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		steps := migration.Plan(migrations, current, version)
+		if len(steps) == 0 {
+			return current, nil
+		}
+		step := steps[0]
+
+		// transactions hold the write lock from the start, another process may have moved the schema while this one
+		// waited for it, the step is planned again if so
+		applied := false
+		err = p.Atomically(ctx, func(tx *sql.Tx) error {
+			var locked int64
+			if err := tx.QueryRowContext(ctx, fetchVersion).Scan(&locked); err != nil {
+				return err
+			}
+			if locked != current {
+				current = locked
+				return nil
+			}
+
+			// the driver runs every statement of a script sent without arguments
+			if _, err := tx.ExecContext(ctx, step.Script()); err != nil {
+				return fmt.Errorf("SQLException: migration %s failed: %w", step.Migration, err)
+			}
+
+			var err error
+			if step.Revert {
+				_, err = tx.ExecContext(ctx, deleteMigration, step.Version)
+			} else {
+				_, err = tx.ExecContext(ctx, importMigration, step.Version, step.Name, time.Now().Unix())
+			}
+			applied = err == nil
+			return err
+		})
+		if err != nil {
+			return current, err
+		}
+		if !applied {
+			continue
+		}
+
+		if step.Revert {
+			log.Printf("INFO: Reverted migration %s", step.Migration)
+		} else {
+			log.Printf("INFO: Applied migration %s", step.Migration)
+		}
+		current = step.Result
+	}
+}
@@ -0,0 +1,27 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMigrateUpAndDown(t *testing.T) {
+	p := open(t)
+	ctx := context.Background()
+
+	if version, err := p.SchemaVersion(ctx); err != nil || version != 2 {
+		t.Fatalf("expected version 2, got %d (%v)", version, err)
+	}
+
+	// applying again is a no-op
+	if version, err := p.Migrate(ctx, -1); err != nil || version != 2 {
+		t.Fatalf("expected version 2, got %d (%v)", version, err)
+	}
+
+	if version, err := p.Migrate(ctx, 0); err != nil || version != 0 {
+		t.Fatalf("expected every migration to be reverted, got %d (%v)", version, err)
+	}
+	if _, err := p.FetchCheckpoints(ctx); err == nil {
+		t.Error("expected reverting 0001_initial to drop the checkpoints table")
+	}
+}
@@ -0,0 +1,10 @@
+DROP VIEW IF EXISTS ticket_view;
+DROP TABLE IF EXISTS ticket_audit;
+DROP TABLE IF EXISTS ticket_metadata;
+DROP TABLE IF EXISTS tickets;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS organizations;
+DROP TABLE IF EXISTS groups;
+DROP TABLE IF EXISTS ticket_metrics;
+DROP TABLE IF EXISTS ticket_fields;
+DROP TABLE IF EXISTS checkpoints;
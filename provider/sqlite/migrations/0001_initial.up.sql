@@ -0,0 +1,108 @@
+/* typed progress markers, value is encoded according to kind: int, timestamp (RFC3339), cursor or json */
+CREATE TABLE IF NOT EXISTS checkpoints (
+	name        TEXT NOT NULL,
+	kind        TEXT NOT NULL,
+	value       TEXT NOT NULL,
+	written_at  INTEGER NOT NULL,
+	run_id      TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (name)
+);
+
+CREATE TABLE IF NOT EXISTS ticket_fields (
+	id          INTEGER NOT NULL,
+	title       TEXT NOT NULL,
+	PRIMARY KEY (id)
+);
+
+/* times are unix seconds as in mysql, zero until set */
+CREATE TABLE IF NOT EXISTS ticket_metrics (
+	id          INTEGER NOT NULL,
+	created_at  INTEGER,
+	updated_at  INTEGER,
+	ticket_id   INTEGER NOT NULL,
+	replies     INTEGER,
+	ttfr        INTEGER,
+	solved_at   INTEGER DEFAULT 0,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS groups (
+	id          INTEGER NOT NULL,
+	name        TEXT NOT NULL,
+	created_at  INTEGER NOT NULL,
+	updated_at  INTEGER NOT NULL,
+	PRIMARY KEY (id)
+);
+
+/* group id is not mandatory for organizations, nor organization id for users */
+INSERT OR IGNORE INTO groups VALUES (0, 'UNDEFINED', 0, 0);
+
+CREATE TABLE IF NOT EXISTS organizations (
+	id          INTEGER NOT NULL,
+	name        TEXT NOT NULL,
+	created_at  INTEGER NOT NULL,
+	updated_at  INTEGER NOT NULL,
+	group_id    INTEGER NOT NULL REFERENCES groups (id),
+	PRIMARY KEY (id)
+);
+
+INSERT OR IGNORE INTO organizations VALUES (0, 'UNDEFINED', 0, 0, 0);
+
+CREATE TABLE IF NOT EXISTS users (
+	id                INTEGER NOT NULL,
+	email             TEXT NOT NULL,
+	name              TEXT NOT NULL,
+	created_at        INTEGER,
+	organization_id   INTEGER DEFAULT 0 REFERENCES organizations (id),
+	default_group_id  INTEGER NOT NULL REFERENCES groups (id),
+	role              TEXT NOT NULL,
+	time_zone         TEXT NOT NULL,
+	updated_at        INTEGER NOT NULL,
+	PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS tickets (
+	id               INTEGER NOT NULL,
+	subject          TEXT NOT NULL,
+	status           TEXT NOT NULL,
+	requester_id     INTEGER NOT NULL REFERENCES users (id),
+	submitter_id     INTEGER NOT NULL REFERENCES users (id),
+	assignee_id      INTEGER NOT NULL REFERENCES users (id),
+	organization_id  INTEGER DEFAULT 0 REFERENCES organizations (id),
+	group_id         INTEGER NOT NULL REFERENCES groups (id),
+	created_at       INTEGER NOT NULL,
+	updated_at       INTEGER NOT NULL,
+	version          TEXT DEFAULT '-',
+	component        TEXT DEFAULT '-',
+	priority         TEXT DEFAULT 'undefined',
+	ttfr             INTEGER,
+	solved_at        INTEGER DEFAULT 0,
+	PRIMARY KEY (id)
+);
+
+/* custom field values as zendesk sent them, encoded as JSON */
+CREATE TABLE IF NOT EXISTS ticket_metadata (
+	ticket_id          INTEGER NOT NULL,
+	field_id           INTEGER NOT NULL REFERENCES ticket_fields (id),
+	raw_value          TEXT,
+	transformed_value  TEXT,
+	PRIMARY KEY (ticket_id, field_id)
+);
+
+/* latest value of the audited custom field per ticket, audit_id orders competing updates */
+CREATE TABLE IF NOT EXISTS ticket_audit (
+	ticket_id  INTEGER NOT NULL REFERENCES tickets (id),
+	audit_id   INTEGER NOT NULL DEFAULT 0,
+	author_id  INTEGER NOT NULL REFERENCES users (id),
+	value      TEXT,
+	PRIMARY KEY (ticket_id)
+);
+
+/* convenience view */
+CREATE VIEW IF NOT EXISTS ticket_view AS SELECT tickets.id, tickets.priority, organizations.name AS organization,
+                                           users.name AS requester, tickets.status, tickets.component, tickets.version,
+                                           datetime(tickets.created_at, 'unixepoch') AS created_at,
+                                           datetime(tickets.solved_at, 'unixepoch') AS solved_at
+                                         FROM tickets
+                                           JOIN organizations ON tickets.organization_id = organizations.id
+                                           JOIN users ON tickets.requester_id = users.id;
@@ -0,0 +1,2 @@
+/* unassigned tickets have an assignee id of 0, mapped like groups and organizations without one */
+INSERT OR IGNORE INTO users VALUES (0, '', 'UNDEFINED', 0, 0, 0, '', '', 0);
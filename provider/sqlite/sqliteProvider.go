@@ -0,0 +1,85 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	"github.com/rnpridgeon/zendb/provider/sink"
+	"github.com/rnpridgeon/zendb/provider/sqlsink"
+	// pure go driver, builds without cgo
+	_ "modernc.org/sqlite"
+)
+
+// targets, named as in the mysql sink so transformations register against either
+const (
+	CHECKPOINTS = sqlsink.CHECKPOINTS
+
+	TICKET_FIELDS       = sink.TICKET_FIELDS
+	TICKET_FIELD_VALUES = sink.TICKET_FIELD_VALUES
+
+	GROUPS        = sink.GROUPS
+	ORGANIZATIONS = sink.ORGANIZATIONS
+	USERS         = sink.USERS
+	TICKETS       = sink.TICKETS
+
+	TICKET_METRICS = sink.TICKET_METRICS
+	TICKET_AUDITS  = sink.TICKET_AUDITS
+)
+
+// DIALECT - raw queries handed to ExecRaw are written for sqlite
+const DIALECT = "sqlite"
+
+// database file used unless configured otherwise
+const defaultPath = "zendb.db"
+
+// SqliteConfig - see the sqlite section of the README
+type SqliteConfig struct {
+	Type string `json:"type"`
+	// database file, created on first use. Defaults to zendb.db in the working directory
+	Path string `json:"path"`
+	// rows written per INSERT statement, defaults to 500
+	BatchSize int `json:"batch_size"`
+}
+
+// SqliteProvider - imports, updates and checkpoints are those of the shared sql sink written in sqlite's dialect
+type SqliteProvider struct {
+	*sqlsink.Provider
+	dbClient *sql.DB
+}
+
+func Open(conf *SqliteConfig) (*SqliteProvider, error) {
+	db, err := sql.Open("sqlite", conf.dsn())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// sqlite allows a single writer, one connection keeps writers from failing on a busy database
+	db.SetMaxOpenConns(1)
+
+	return &SqliteProvider{sqlsink.New(db, dialect{}, conf.BatchSize), db}, nil
+}
+
+// dsn - the driver connection string, foreign keys are enforced as they are by mysql and transactions take the
+// write lock up front so a checkpoint read within one cannot go stale
+func (c *SqliteConfig) dsn() string {
+	path := c.Path
+	if path == "" {
+		path = defaultPath
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Set("_txlock", "immediate")
+	return "file:" + path + "?" + params.Encode()
+}
+
+// Close - releases the database file
+func (p *SqliteProvider) Close() error {
+	return p.dbClient.Close()
+}
+
+func (p *SqliteProvider) Dialect() string {
+	return DIALECT
+}
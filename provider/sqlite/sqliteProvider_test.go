@@ -0,0 +1,171 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+// open - a migrated database in a temporary directory
+func open(t *testing.T) *SqliteProvider {
+	p, err := Open(&SqliteConfig{Path: filepath.Join(t.TempDir(), "zendb.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+
+	if _, err = p.Migrate(context.Background(), -1); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func testPage() models.Ticket_page {
+	created := time.Unix(1500000000, 0)
+	return models.Ticket_page{
+		Groups:        []models.Group{{Id: 3, Name: "support", Created_at: created, Updated_at: created}},
+		Organizations: []models.Organization{{Id: 5, Name: "acme", Group_id: 3, Created_at: created, Updated_at: created}},
+		Users:         []models.User{{Id: 7, Name: "jane", Email: "jane@acme.com", Organization_id: 5, Default_group_id: 3, Updated_at: created}},
+		Tickets: []models.Ticket{
+			{Id: 11, Subject: "broker down", Status: "open", Requester_id: 7, Submitter_id: 7, Assignee_id: 7,
+				Organization_id: 5, Group_id: 3, Created_at: created, Updated_at: created,
+				Custom_fields: []models.Custom_fields{{Id: 1, Value: "3.1"}}},
+		},
+		Metric_sets: []models.Ticket_metrics{{Id: 13, Ticket_id: 11, Solved_at: created.Add(time.Hour)}},
+	}
+}
+
+func TestImportTicketsCommitsRowsWithCheckpoints(t *testing.T) {
+	p := open(t)
+	ctx := context.Background()
+
+	if err := p.ImportTicketFields(ctx, []models.Ticket_field{{Id: 1, Title: "Kafka Version"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ImportTickets(ctx, testPage(), models.CursorCheckpoint("ticket_export", "abc")); err != nil {
+		t.Fatal(err)
+	}
+
+	tickets, err := p.ExportTickets(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tickets) != 1 || tickets[0].Id != 11 || tickets[0].Created_at.Unix() != 1500000000 {
+		t.Fatalf("expected the ticket to be written, got %+v", tickets)
+	}
+
+	checkpoints, err := p.FetchCheckpoints(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoints["ticket_export"].Cursor() != "abc" || checkpoints[TICKETS].Int() != 11 ||
+		checkpoints[TICKET_METRICS].Int() != 11 || checkpoints[USERS].Int() != 7 {
+		t.Errorf("unexpected checkpoints %v", checkpoints)
+	}
+
+	if err = p.CommitCheckpoint(ctx, models.IntCheckpoint(TICKETS, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if checkpoints, _ = p.FetchCheckpoints(ctx); checkpoints[TICKETS].Int() != 11 {
+		t.Errorf("expected the tickets checkpoint not to move backwards, got %v", checkpoints[TICKETS])
+	}
+}
+
+func TestImportTicketsAcceptsUnassignedTickets(t *testing.T) {
+	p := open(t)
+	ctx := context.Background()
+
+	if err := p.ImportTicketFields(ctx, []models.Ticket_field{{Id: 1, Title: "Kafka Version"}}); err != nil {
+		t.Fatal(err)
+	}
+	page := testPage()
+	// zendesk leaves assignee_id null until a ticket is assigned, it decodes as 0
+	page.Tickets[0].Assignee_id = 0
+	if err := p.ImportTickets(ctx, page, models.CursorCheckpoint("ticket_export", "abc")); err != nil {
+		t.Fatal(err)
+	}
+
+	if tickets, err := p.ExportTickets(ctx, 0, 0); err != nil || len(tickets) != 1 || tickets[0].Assignee_id != 0 {
+		t.Errorf("expected the unassigned ticket to be written, got %+v %v", tickets, err)
+	}
+}
+
+func TestImportTicketsRejectingARowCommitsNothing(t *testing.T) {
+	p := open(t)
+	ctx := context.Background()
+
+	page := testPage()
+	// references a user which was never imported
+	page.Tickets = append(page.Tickets, models.Ticket{Id: 12, Subject: "orphan", Status: "open",
+		Requester_id: 99, Submitter_id: 99, Assignee_id: 99, Group_id: 3, Updated_at: time.Unix(1500000000, 0)})
+	if err := p.ImportTickets(ctx, page, models.CursorCheckpoint("ticket_export", "abc")); err == nil {
+		t.Fatal("expected the orphaned ticket to fail the import")
+	}
+
+	if tickets, err := p.ExportTickets(ctx, 0, 0); err != nil || len(tickets) != 0 {
+		t.Errorf("expected the page to be rolled back, got %+v %v", tickets, err)
+	}
+	if checkpoints, _ := p.FetchCheckpoints(ctx); len(checkpoints) != 0 {
+		t.Errorf("expected neither the tickets checkpoint nor the cursor to advance, got %v", checkpoints)
+	}
+}
+
+func TestUpdateTicketSetsOnlyNamedColumns(t *testing.T) {
+	p := open(t)
+	ctx := context.Background()
+
+	if err := p.ImportTicketFields(ctx, []models.Ticket_field{{Id: 1, Title: "Kafka Version"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ImportTickets(ctx, testPage()); err != nil {
+		t.Fatal(err)
+	}
+
+	ticket := models.Ticket_Enhanced{Ticket: models.Ticket{Id: 11, Subject: "renamed"}, Version: "3.1", Priority: "P1"}
+	if err := p.UpdateTicket(ctx, []string{"version", "priority"}, ticket); err != nil {
+		t.Fatal(err)
+	}
+
+	tickets, err := p.ExportTickets(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := tickets[0]; e.Version != "3.1" || e.Priority != "P1" || e.Subject != "broker down" {
+		t.Errorf("expected only version and priority to change, got %+v", e)
+	}
+}
+
+func TestImportAuditKeepsNewest(t *testing.T) {
+	p := open(t)
+	ctx := context.Background()
+
+	if err := p.ImportTicketFields(ctx, []models.Ticket_field{{Id: 1, Title: "Kafka Version"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ImportTickets(ctx, testPage()); err != nil {
+		t.Fatal(err)
+	}
+
+	audit := func(id int64, value string) models.Audit {
+		return models.Audit{Id: id, Ticket_id: 11, Author_id: 7,
+			Events: []models.Event{{Type: "Change", Field_name: "34347708", Value: value}}}
+	}
+	// newest first within a page, then an older audit arriving later
+	if err := p.ImportAudit(ctx, []models.Audit{audit(30, "newest"), audit(20, "older")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ImportAudit(ctx, []models.Audit{audit(10, "oldest")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var value string
+	if err := p.dbClient.QueryRow("SELECT value FROM ticket_audit WHERE ticket_id = 11").Scan(&value); err != nil {
+		t.Fatal(err)
+	}
+	if value != "newest" {
+		t.Errorf("expected the newest audit to win, got %s", value)
+	}
+}
@@ -0,0 +1,549 @@
+package sqlsink
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+	"github.com/rnpridgeon/zendb/provider/sink"
+)
+
+// CHECKPOINTS - table holding the committed checkpoints
+const CHECKPOINTS = "checkpoints"
+
+// Dialect - how one database's SQL differs from another's, everything else about writing to it is shared
+type Dialect interface {
+	// Param - placeholder of the nth parameter of a statement, counting from one
+	Param(n int) string
+	// Quote - name escaped as an identifier
+	Quote(name string) string
+	// OnConflict - clause completing an INSERT into t which refreshes t.Updates of the rows already there
+	OnConflict(t Table) string
+	// ForUpdate - clause locking the rows a SELECT reads until the transaction ends, if the transaction needs one
+	ForUpdate() string
+	// MaxParams - most parameters a single statement may hold
+	MaxParams() int
+	// Time - a time as stored, Unset is stored in its place where no time is set, e.g. for unsolved tickets
+	Time(at time.Time) interface{}
+	Unset() interface{}
+	// Value - a custom field value, kept as zendesk sent it
+	Value(val interface{}) interface{}
+	// Savepoints - whether a failed statement aborts the transaction unless it ran under a savepoint
+	Savepoints() bool
+	// Temporary - whether the database reported err for a condition it expects to recover from, e.g. a deadlock.
+	// Dropped connections are temporary whatever the dialect.
+	Temporary(err error) bool
+}
+
+// conn - statements run either inside an import transaction or directly against the database
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Provider - a sink writing to a database through database/sql in its dialect, the mysql, postgres and sqlite
+// sinks wrap one along with their configuration and migrations. Safe for concurrent use.
+type Provider struct {
+	dbClient        *sql.DB
+	dialect         Dialect
+	transformations sink.Transformations
+	batchSize       int
+
+	// statements written in the dialect
+	importCheckpoint   string
+	updateCheckpoint   string
+	fetchCheckpoints   string
+	lockCheckpoint     string
+	resetCheckpoint    string
+	fetchOrganizations string
+	fetchTickets       string
+}
+
+// New - a non-positive batchSize writes DEFAULT_BATCH_SIZE rows per statement
+func New(db *sql.DB, dialect Dialect, batchSize int) *Provider {
+	if batchSize <= 0 {
+		batchSize = DEFAULT_BATCH_SIZE
+	}
+
+	p := dialect.Param
+	return &Provider{
+		dbClient:        db,
+		dialect:         dialect,
+		transformations: make(sink.Transformations),
+		batchSize:       batchSize,
+
+		importCheckpoint: fmt.Sprintf("INSERT INTO %s (name, kind, value, written_at, run_id) VALUES (%s, %s, %s, %s, %s)",
+			CHECKPOINTS, p(1), p(2), p(3), p(4), p(5)),
+		updateCheckpoint: fmt.Sprintf("UPDATE %s SET kind = %s, value = %s, written_at = %s, run_id = %s WHERE name = %s",
+			CHECKPOINTS, p(1), p(2), p(3), p(4), p(5)),
+		fetchCheckpoints: "SELECT name, kind, value, written_at, run_id FROM " + CHECKPOINTS,
+		lockCheckpoint:   fmt.Sprintf("SELECT kind, value FROM %s WHERE name = %s%s", CHECKPOINTS, p(1), dialect.ForUpdate()),
+		resetCheckpoint:  fmt.Sprintf("DELETE FROM %s WHERE name = %s", CHECKPOINTS, p(1)),
+
+		fetchOrganizations: "SELECT id, name, created_at, updated_at, group_id FROM organizations " +
+			"WHERE name NOT LIKE '%deleted%' AND id > 0 AND updated_at >= " + p(1) + " ORDER BY name ASC",
+		fetchTickets: "SELECT id, subject, status, requester_id, submitter_id, assignee_id, organization_id, group_id, " +
+			"created_at, updated_at, version, component, priority, COALESCE(ttfr, 0), solved_at FROM tickets " +
+			"WHERE updated_at >= " + p(1) + " AND status != 'deleted' ORDER BY organization_id ASC, id DESC",
+	}
+}
+
+func (p *Provider) RegisterTransformation(target string, fn func(interface{})) {
+	p.transformations.Register(target, fn)
+}
+
+func (p *Provider) FetchCheckpoints(ctx context.Context) (checkpoints map[string]models.Checkpoint, err error) {
+	rows, err := p.dbClient.QueryContext(ctx, p.fetchCheckpoints)
+	if err != nil {
+		return nil, p.classify(err)
+	}
+	defer rows.Close()
+
+	checkpoints = make(map[string]models.Checkpoint)
+	for rows.Next() {
+		var c models.Checkpoint
+		if err = rows.Scan(&c.Name, &c.Kind, &c.Value, storedTime{&c.Written_at}, &c.Run_id); err != nil {
+			return nil, err
+		}
+		checkpoints[c.Name] = c
+	}
+	return checkpoints, rows.Err()
+}
+
+// ExecRaw - runs qry as is, callers check the sink's dialect to know which SQL it is expected in
+func (p *Provider) ExecRaw(ctx context.Context, qry string) (int64, error) {
+	results, err := p.dbClient.ExecContext(ctx, qry)
+	if err != nil {
+		return 0, err
+	}
+	return results.RowsAffected()
+}
+
+// CommitCheckpoint - records checkpoint on its own, imports commit theirs alongside the rows they cover
+func (p *Provider) CommitCheckpoint(ctx context.Context, checkpoint models.Checkpoint) error {
+	return p.Atomically(ctx, func(tx *sql.Tx) error {
+		return p.commitCheckpoints(ctx, tx, checkpoint)
+	})
+}
+
+// commitCheckpoints - stamps each checkpoint with the current time unless it was already written.
+// Checkpoints which would move progress backwards, see models.Checkpoint.Advances, are skipped.
+func (p *Provider) commitCheckpoints(ctx context.Context, c conn, checkpoints ...models.Checkpoint) error {
+	now := time.Now()
+	for _, checkpoint := range checkpoints {
+		if checkpoint.Written_at.IsZero() {
+			checkpoint.Written_at = now
+		}
+		writtenAt := p.dialect.Time(checkpoint.Written_at)
+
+		var prev models.Checkpoint
+		err := c.QueryRowContext(ctx, p.lockCheckpoint, checkpoint.Name).Scan(&prev.Kind, &prev.Value)
+		switch {
+		case err == sql.ErrNoRows:
+			_, err = c.ExecContext(ctx, p.importCheckpoint, checkpoint.Name, checkpoint.Kind, checkpoint.Value, writtenAt, checkpoint.Run_id)
+		case err == nil && !checkpoint.Advances(prev):
+			continue
+		case err == nil:
+			_, err = c.ExecContext(ctx, p.updateCheckpoint, checkpoint.Kind, checkpoint.Value, writtenAt, checkpoint.Run_id, checkpoint.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("SQLException: failed to commit %v to %s: %w", checkpoint.Name, CHECKPOINTS, err)
+		}
+	}
+	return nil
+}
+
+// Atomically - runs fn in a transaction, committing only if fn succeeds. Errors the next attempt is expected to
+// get past are returned as a TemporaryError.
+func (p *Provider) Atomically(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := p.dbClient.BeginTx(ctx, nil)
+	if err != nil {
+		return p.classify(err)
+	}
+	defer tx.Rollback()
+
+	if err = fn(tx); err != nil {
+		return p.classify(err)
+	}
+	return p.classify(tx.Commit())
+}
+
+// TemporaryError - a database error expected to succeed when retried, such as a dropped connection or a lock
+// wait timeout, see Dialect.Temporary
+type TemporaryError struct {
+	Err error
+}
+
+func (e *TemporaryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TemporaryError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TemporaryError) Temporary() bool {
+	return true
+}
+
+// classify - err as a TemporaryError where the database is expected to recover from it, cancellations never are
+func (p *Provider) classify(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr) ||
+		p.dialect.Temporary(err) {
+		return &TemporaryError{err}
+	}
+	return err
+}
+
+func (p *Provider) ResetCheckpoint(ctx context.Context, name string) error {
+	_, err := p.dbClient.ExecContext(ctx, p.resetCheckpoint, name)
+	if err != nil {
+		return fmt.Errorf("SQLException: failed to reset %v in %s: %w", name, CHECKPOINTS, err)
+	}
+	return nil
+}
+
+// Each import writes its rows, the id of the last row written and the given checkpoints in a single transaction,
+// a checkpoint is never durable without the rows it covers.
+
+func (p *Provider) ImportTicketFields(ctx context.Context, entities []models.Ticket_field, checkpoints ...models.Checkpoint) error {
+	return p.Atomically(ctx, func(tx *sql.Tx) error {
+		last, err := p.importTicketFields(ctx, tx, entities)
+		if err != nil {
+			return err
+		}
+		return p.commitCheckpoints(ctx, tx, append(checkpoints, models.IntCheckpoint(sink.TICKET_FIELDS, last))...)
+	})
+}
+
+func (p *Provider) ImportGroups(ctx context.Context, entities []models.Group, checkpoints ...models.Checkpoint) error {
+	return p.Atomically(ctx, func(tx *sql.Tx) error {
+		last, err := p.importGroups(ctx, tx, entities)
+		if err != nil {
+			return err
+		}
+		return p.commitCheckpoints(ctx, tx, append(checkpoints, models.IntCheckpoint(sink.GROUPS, last))...)
+	})
+}
+
+func (p *Provider) ImportOrganizations(ctx context.Context, entities []models.Organization, checkpoints ...models.Checkpoint) error {
+	defer sink.TimeTrack(time.Now(), "Organization Import")
+
+	return p.Atomically(ctx, func(tx *sql.Tx) error {
+		last, err := p.importOrganizations(ctx, tx, entities)
+		if err != nil {
+			return err
+		}
+		return p.commitCheckpoints(ctx, tx, append(checkpoints, models.IntCheckpoint(sink.ORGANIZATIONS, last))...)
+	})
+}
+
+func (p *Provider) ImportUsers(ctx context.Context, entities []models.User, checkpoints ...models.Checkpoint) error {
+	defer sink.TimeTrack(time.Now(), "User import")
+
+	return p.Atomically(ctx, func(tx *sql.Tx) error {
+		last, err := p.importUsers(ctx, tx, entities)
+		if err != nil {
+			return err
+		}
+		return p.commitCheckpoints(ctx, tx, append(checkpoints, models.IntCheckpoint(sink.USERS, last))...)
+	})
+}
+
+// ImportTickets - sideloaded records are written first, groups ahead of the organizations and users referring to them,
+// so every ticket's references already exist
+func (p *Provider) ImportTickets(ctx context.Context, page models.Ticket_page, checkpoints ...models.Checkpoint) error {
+	defer sink.TimeTrack(time.Now(), "Ticket import")
+
+	return p.Atomically(ctx, func(tx *sql.Tx) error {
+		steps := []struct {
+			table string
+			size  int
+			write func() (int64, error)
+		}{
+			{sink.GROUPS, len(page.Groups), func() (int64, error) { return p.importGroups(ctx, tx, page.Groups) }},
+			{sink.ORGANIZATIONS, len(page.Organizations), func() (int64, error) { return p.importOrganizations(ctx, tx, page.Organizations) }},
+			{sink.USERS, len(page.Users), func() (int64, error) { return p.importUsers(ctx, tx, page.Users) }},
+			{sink.TICKETS, len(page.Tickets), func() (int64, error) { return p.importTickets(ctx, tx, page.Tickets) }},
+			{sink.TICKET_METRICS, len(page.Metric_sets), func() (int64, error) { return p.importTicketMetrics(ctx, tx, page.Metric_sets) }},
+		}
+
+		for _, step := range steps {
+			if step.size == 0 {
+				continue
+			}
+			last, err := step.write()
+			if err != nil {
+				return err
+			}
+			checkpoints = append(checkpoints, models.IntCheckpoint(step.table, last))
+		}
+		return p.commitCheckpoints(ctx, tx, checkpoints...)
+	})
+}
+
+func (p *Provider) ImportTicketFieldValues(ctx context.Context, parent int64, entities []models.Custom_fields) error {
+	return p.Atomically(ctx, func(tx *sql.Tx) error {
+		return p.importTicketFieldValues(ctx, tx, parent, entities)
+	})
+}
+
+func (p *Provider) ImportTicketMetrics(ctx context.Context, entities []models.Ticket_metrics, checkpoints ...models.Checkpoint) error {
+	return p.Atomically(ctx, func(tx *sql.Tx) error {
+		last, err := p.importTicketMetrics(ctx, tx, entities)
+		if err != nil {
+			return err
+		}
+		return p.commitCheckpoints(ctx, tx, append(checkpoints, models.IntCheckpoint(sink.TICKET_METRICS, last))...)
+	})
+}
+
+// ImportAudit - unlike other imports no table checkpoint is written, the pipeline tracks audits by id itself
+func (p *Provider) ImportAudit(ctx context.Context, entities []models.Audit, checkpoints ...models.Checkpoint) error {
+	defer sink.TimeTrack(time.Now(), "Audit import")
+
+	return p.Atomically(ctx, func(tx *sql.Tx) error {
+		if err := p.importAudit(ctx, tx, entities); err != nil {
+			return err
+		}
+		return p.commitCheckpoints(ctx, tx, checkpoints...)
+	})
+}
+
+func (p *Provider) importGroups(ctx context.Context, c conn, entities []models.Group) (last int64, err error) {
+	rows := make([][]interface{}, 0, len(entities))
+	last = p.transformations.Groups(entities, func(e models.Group) {
+		rows = append(rows, p.groupRow(e))
+	})
+
+	return last, p.upsert(ctx, c, groupsTable, rows)
+}
+
+func (p *Provider) UpdateGroup(ctx context.Context, updates []string, entity models.Group) error {
+	return p.update(ctx, p.dbClient, groupsTable, updates, p.groupRow(entity))
+}
+
+func (p *Provider) importOrganizations(ctx context.Context, c conn, entities []models.Organization) (last int64, err error) {
+	rows := make([][]interface{}, 0, len(entities))
+	last = p.transformations.Organizations(entities, func(e models.Organization) {
+		rows = append(rows, p.organizationRow(e))
+	})
+
+	return last, p.upsert(ctx, c, organizationsTable, rows)
+}
+
+func (p *Provider) UpdateOrganization(ctx context.Context, updates []string, entity models.Organization) error {
+	return p.update(ctx, p.dbClient, organizationsTable, updates, p.organizationRow(entity))
+}
+
+func (p *Provider) ExportOrganizations(ctx context.Context, since int64) (entities []models.Organization, err error) {
+	defer sink.TimeTrack(time.Now(), "Organization export")
+
+	rows, err := p.dbClient.QueryContext(ctx, p.fetchOrganizations, p.dialect.Time(time.Unix(since, 0)))
+	if err != nil {
+		return nil, fmt.Errorf("SQLException: failed to fetch from %s: %w", sink.ORGANIZATIONS, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.Organization
+		if err = rows.Scan(&e.Id, &e.Name, storedTime{&e.Created_at}, storedTime{&e.Updated_at}, &e.Group_id); err != nil {
+			return entities, err
+		}
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
+
+func (p *Provider) importUsers(ctx context.Context, c conn, entities []models.User) (last int64, err error) {
+	rows := make([][]interface{}, 0, len(entities))
+	last = p.transformations.Users(entities, func(e models.User) {
+		rows = append(rows, p.userRow(e))
+	})
+
+	return last, p.upsert(ctx, c, usersTable, rows)
+}
+
+func (p *Provider) UpdateUser(ctx context.Context, updates []string, entity models.User) error {
+	return p.update(ctx, p.dbClient, usersTable, updates, p.userRow(entity))
+}
+
+// importTickets - custom field values belong to the ticket, they are written in the same transaction
+func (p *Provider) importTickets(ctx context.Context, c conn, entities []models.Ticket) (last int64, err error) {
+	rows := make([][]interface{}, 0, len(entities))
+	var values [][]interface{}
+	last = p.transformations.Tickets(entities, func(e models.Ticket) {
+		rows = append(rows, p.ticketRow(models.Ticket_Enhanced{Ticket: e}))
+		for _, v := range e.Custom_fields {
+			values = append(values, p.ticketFieldValueRow(e.Id, v))
+		}
+	})
+
+	if err = p.upsert(ctx, c, ticketsTable, rows); err != nil {
+		return 0, err
+	}
+	return last, p.upsert(ctx, c, ticketFieldValuesTable, values)
+}
+
+// UpdateTicket - unlike imports, updates may name the version, component, priority, ttfr and solved_at columns
+func (p *Provider) UpdateTicket(ctx context.Context, updates []string, entity models.Ticket_Enhanced) error {
+	return p.update(ctx, p.dbClient, ticketsTable, updates, p.ticketRow(entity))
+}
+
+func (p *Provider) ExportTickets(ctx context.Context, since int64, orgID int64) (entities []models.Ticket_Enhanced, err error) {
+	defer sink.TimeTrack(time.Now(), "Ticket export")
+
+	rows, err := p.dbClient.QueryContext(ctx, p.fetchTickets, p.dialect.Time(time.Unix(since, 0)))
+	if err != nil {
+		return nil, fmt.Errorf("SQLException: failed to fetch from %s: %w", sink.TICKETS, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.Ticket_Enhanced
+		err = rows.Scan(&e.Id, &e.Subject, &e.Status, &e.Requester_id, &e.Submitter_id, &e.Assignee_id,
+			&e.Organization_id, &e.Group_id, storedTime{&e.Created_at}, storedTime{&e.Updated_at}, &e.Version, &e.Component,
+			&e.Priority, &e.TTFR, storedTime{&e.Solved_at})
+		if err != nil {
+			return entities, err
+		}
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
+
+func (p *Provider) importTicketFields(ctx context.Context, c conn, entities []models.Ticket_field) (last int64, err error) {
+	rows := make([][]interface{}, 0, len(entities))
+	last = p.transformations.TicketFields(entities, func(e models.Ticket_field) {
+		rows = append(rows, ticketFieldRow(e))
+	})
+
+	return last, p.upsert(ctx, c, ticketFieldsTable, rows)
+}
+
+func (p *Provider) UpdateTicketField(ctx context.Context, updates []string, entity models.Ticket_field) error {
+	return p.update(ctx, p.dbClient, ticketFieldsTable, updates, ticketFieldRow(entity))
+}
+
+func (p *Provider) importTicketFieldValues(ctx context.Context, c conn, parent int64, entities []models.Custom_fields) error {
+	rows := make([][]interface{}, 0, len(entities))
+	p.transformations.FieldValues(entities, func(e models.Custom_fields) {
+		rows = append(rows, p.ticketFieldValueRow(parent, e))
+	})
+
+	return p.upsert(ctx, c, ticketFieldValuesTable, rows)
+}
+
+func (p *Provider) UpdateTicketFieldValues(ctx context.Context, updates []string, parent int64, entity models.Custom_fields) error {
+	return p.update(ctx, p.dbClient, ticketFieldValuesTable, updates, p.ticketFieldValueRow(parent, entity))
+}
+
+func (p *Provider) importTicketMetrics(ctx context.Context, c conn, entities []models.Ticket_metrics) (last int64, err error) {
+	rows := make([][]interface{}, 0, len(entities))
+	last = p.transformations.TicketMetrics(entities, func(e models.Ticket_metrics) {
+		rows = append(rows, p.ticketMetricRow(e))
+	})
+
+	return last, p.upsert(ctx, c, ticketMetricsTable, rows)
+}
+
+func (p *Provider) UpdateTicketMetric(ctx context.Context, updates []string, entity models.Ticket_metrics) error {
+	return p.update(ctx, p.dbClient, ticketMetricsTable, updates, p.ticketMetricRow(entity))
+}
+
+// importAudit - a ticket holds a single audited value, only the newest audit per ticket is written.
+// Postgres refuses to update a row twice in one statement.
+func (p *Provider) importAudit(ctx context.Context, c conn, entities []models.Audit) error {
+	var rows [][]interface{}
+	newest := make(map[int64]int)
+
+	fieldID := strconv.FormatInt(34347708, 10)
+	p.transformations.Audits(entities, func(e models.Audit) {
+		for _, se := range e.Events {
+			if se.Type != "Change" || se.Field_name != fieldID {
+				continue
+			}
+
+			i, seen := newest[e.Ticket_id]
+			switch {
+			case !seen:
+				newest[e.Ticket_id] = len(rows)
+				rows = append(rows, auditRow(e, se))
+			case e.Id > rows[i][1].(int64):
+				rows[i] = auditRow(e, se)
+			}
+		}
+	})
+
+	return p.upsert(ctx, c, ticketAuditsTable, rows)
+}
+
+// Row builders, values are in the column order of the matching table and times encoded by the dialect
+
+func (p *Provider) groupRow(e models.Group) []interface{} {
+	return []interface{}{e.Id, e.Name, p.dialect.Time(e.Created_at), p.dialect.Time(e.Updated_at)}
+}
+
+func (p *Provider) organizationRow(e models.Organization) []interface{} {
+	return []interface{}{e.Id, e.Name, p.dialect.Time(e.Created_at), p.dialect.Time(e.Updated_at), e.Group_id}
+}
+
+// userRow - zendesk may omit when a user was created, the column is left NULL then
+func (p *Provider) userRow(e models.User) []interface{} {
+	var created interface{}
+	if e.Created_at != nil {
+		created = p.dialect.Time(*e.Created_at)
+	}
+	return []interface{}{e.Id, e.Email, e.Name, created, e.Organization_id,
+		e.Default_group_id, e.Role, e.Time_zone, p.dialect.Time(e.Updated_at)}
+}
+
+func (p *Provider) ticketRow(e models.Ticket_Enhanced) []interface{} {
+	return []interface{}{e.Id, e.Subject, e.Status, e.Requester_id, e.Submitter_id, e.Assignee_id,
+		e.Organization_id, e.Group_id, p.dialect.Time(e.Created_at), p.dialect.Time(e.Updated_at), e.Version, e.Component,
+		e.Priority, e.TTFR, p.timeOrUnset(e.Solved_at)}
+}
+
+func ticketFieldRow(e models.Ticket_field) []interface{} {
+	return []interface{}{e.Id, e.Title}
+}
+
+func (p *Provider) ticketFieldValueRow(parent int64, e models.Custom_fields) []interface{} {
+	return []interface{}{parent, e.Id, p.dialect.Value(e.Value), e.Transformed}
+}
+
+func (p *Provider) ticketMetricRow(e models.Ticket_metrics) []interface{} {
+	return []interface{}{e.Id, p.dialect.Time(e.Created_at), p.dialect.Time(e.Updated_at), e.Ticket_id, e.Replies,
+		replyTime(e), p.timeOrUnset(e.Solved_at)}
+}
+
+func auditRow(e models.Audit, se models.Event) []interface{} {
+	return []interface{}{e.Ticket_id, e.Id, e.Author_id, se.Value}
+}
+
+// replyTime - business minutes until the first reply, zero until someone replies
+func replyTime(entity models.Ticket_metrics) int64 {
+	if entity.Reply_time_in_minutes == nil {
+		return 0
+	}
+	return entity.Reply_time_in_minutes.Business
+}
+
+// timeOrUnset - times which are not set, e.g. of unsolved tickets, predate the epoch
+func (p *Provider) timeOrUnset(at time.Time) interface{} {
+	if at.Unix() <= 0 {
+		return p.dialect.Unset()
+	}
+	return p.dialect.Time(at)
+}
@@ -0,0 +1,35 @@
+package sqlsink
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// storedTime - scans a time however the dialect stores it, as unix seconds or a timestamp. NULL scans as the epoch,
+// which is where unset times stored as zero read back.
+type storedTime struct {
+	at *time.Time
+}
+
+func (t storedTime) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*t.at = time.Unix(0, 0)
+	case int64:
+		*t.at = time.Unix(v, 0)
+	case uint64:
+		*t.at = time.Unix(int64(v), 0)
+	case []byte:
+		unix, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("expected unix seconds, got %q", v)
+		}
+		*t.at = time.Unix(unix, 0)
+	case time.Time:
+		*t.at = v
+	default:
+		return fmt.Errorf("cannot scan %T into a time", src)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package sqlsink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rnpridgeon/zendb/provider/sink"
+)
+
+// rows written per INSERT statement unless configured otherwise
+const DEFAULT_BATCH_SIZE = 500
+
+// Table - how a model is written, Updates lists the columns refreshed when a row with the same key already exists.
+// When Newer is set a conflicting row is only refreshed if the incoming value of that column is greater.
+type Table struct {
+	Name    string
+	Columns []string
+	Keys    []string
+	Updates []string
+	Newer   string
+}
+
+var (
+	ticketFieldsTable = Table{
+		Name:    sink.TICKET_FIELDS,
+		Columns: []string{"id", "title"},
+		Keys:    []string{"id"},
+		Updates: []string{"title"},
+	}
+	ticketFieldValuesTable = Table{
+		Name:    sink.TICKET_FIELD_VALUES,
+		Columns: []string{"ticket_id", "field_id", "raw_value", "transformed_value"},
+		Keys:    []string{"ticket_id", "field_id"},
+		Updates: []string{"raw_value", "transformed_value"},
+	}
+	groupsTable = Table{
+		Name:    sink.GROUPS,
+		Columns: []string{"id", "name", "created_at", "updated_at"},
+		Keys:    []string{"id"},
+		Updates: []string{"name", "created_at", "updated_at"},
+	}
+	organizationsTable = Table{
+		Name:    sink.ORGANIZATIONS,
+		Columns: []string{"id", "name", "created_at", "updated_at", "group_id"},
+		Keys:    []string{"id"},
+		Updates: []string{"name", "created_at", "updated_at", "group_id"},
+	}
+	usersTable = Table{
+		Name: sink.USERS,
+		Columns: []string{"id", "email", "name", "created_at", "organization_id", "default_group_id", "role",
+			"time_zone", "updated_at"},
+		Keys: []string{"id"},
+		Updates: []string{"email", "name", "created_at", "organization_id", "default_group_id", "role",
+			"time_zone", "updated_at"},
+	}
+	// version, component, priority, ttfr and solved_at are filled in by post processing, refreshes leave them be
+	ticketsTable = Table{
+		Name: sink.TICKETS,
+		Columns: []string{"id", "subject", "status", "requester_id", "submitter_id", "assignee_id",
+			"organization_id", "group_id", "created_at", "updated_at", "version", "component", "priority", "ttfr", "solved_at"},
+		Keys: []string{"id"},
+		Updates: []string{"subject", "status", "requester_id", "submitter_id", "assignee_id",
+			"organization_id", "group_id", "created_at", "updated_at"},
+	}
+	ticketMetricsTable = Table{
+		Name:    sink.TICKET_METRICS,
+		Columns: []string{"id", "created_at", "updated_at", "ticket_id", "replies", "ttfr", "solved_at"},
+		Keys:    []string{"id"},
+		Updates: []string{"created_at", "updated_at", "ticket_id", "replies", "ttfr", "solved_at"},
+	}
+	// audits are exported newest first, an older audit never replaces the value of a newer one
+	ticketAuditsTable = Table{
+		Name:    sink.TICKET_AUDITS,
+		Columns: []string{"ticket_id", "audit_id", "author_id", "value"},
+		Keys:    []string{"ticket_id"},
+		Updates: []string{"audit_id", "author_id", "value"},
+		Newer:   "audit_id",
+	}
+)
+
+// OnConflict - ON CONFLICT DO UPDATE as postgres and sqlite write it, for dialects to return from their OnConflict
+func OnConflict(d Dialect, t Table) string {
+	assignments := make([]string, len(t.Updates))
+	for i, col := range t.Updates {
+		assignments[i] = fmt.Sprintf("%s = excluded.%s", d.Quote(col), d.Quote(col))
+	}
+
+	clause := fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", quoteAll(d, t.Keys), strings.Join(assignments, ", "))
+	if t.Newer != "" {
+		clause += fmt.Sprintf(" WHERE excluded.%s > %s.%s", d.Quote(t.Newer), d.Quote(t.Name), d.Quote(t.Newer))
+	}
+	return clause
+}
+
+func quoteAll(d Dialect, names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = d.Quote(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// params - the parameters of n rows of size values each
+func params(d Dialect, n, size int) string {
+	rows := make([]string, n)
+	for i := range rows {
+		row := make([]string, size)
+		for j := range row {
+			row[j] = d.Param(i*size + j + 1)
+		}
+		rows[i] = "(" + strings.Join(row, ", ") + ")"
+	}
+	return strings.Join(rows, ", ")
+}
+
+// upsertQuery - INSERT writing n rows of t, refreshing those already there
+func (p *Provider) upsertQuery(t Table, n int) string {
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s %s",
+		p.dialect.Quote(t.Name), quoteAll(p.dialect, t.Columns), params(p.dialect, n, len(t.Columns)), p.dialect.OnConflict(t))
+}
+
+// upsert - writes rows in batches of p.batchSize. A batch which fails is retried row by row to find the row at fault,
+// its error fails the import so the page is rolled back and exported again rather than skipped
+func (p *Provider) upsert(ctx context.Context, c conn, t Table, rows [][]interface{}) error {
+	size := p.batchSize
+	if limit := p.dialect.MaxParams() / len(t.Columns); size > limit {
+		size = limit
+	}
+
+	for start := 0; start < len(rows); start += size {
+		end := start + size
+		if end > len(rows) {
+			end = len(rows)
+		}
+		batch := rows[start:end]
+
+		err := p.exec(ctx, c, p.upsertQuery(t, len(batch)), flatten(batch)...)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			continue
+		}
+		if len(batch) == 1 {
+			return fmt.Errorf("SQLException: failed to insert %v into %s: %w", batch[0][0], t.Name, err)
+		}
+
+		for _, row := range batch {
+			err = p.exec(ctx, c, p.upsertQuery(t, 1), row...)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				return fmt.Errorf("SQLException: failed to insert %v into %s: %w", row[0], t.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// exec - runs qry within an import. Where a failed statement aborts the transaction it runs under a savepoint,
+// rolling back only its own effects should it fail so the retries can go ahead.
+func (p *Provider) exec(ctx context.Context, c conn, qry string, args ...interface{}) error {
+	if !p.dialect.Savepoints() {
+		_, err := c.ExecContext(ctx, qry, args...)
+		return err
+	}
+
+	if _, err := c.ExecContext(ctx, "SAVEPOINT upsert"); err != nil {
+		return err
+	}
+	if _, err := c.ExecContext(ctx, qry, args...); err != nil {
+		if _, rollbackErr := c.ExecContext(ctx, "ROLLBACK TO SAVEPOINT upsert"); rollbackErr != nil {
+			return rollbackErr
+		}
+		return err
+	}
+	_, err := c.ExecContext(ctx, "RELEASE SAVEPOINT upsert")
+	return err
+}
+
+// update - sets only the named columns of the row sharing row's key, an empty list refreshes every column an upsert would
+func (p *Provider) update(ctx context.Context, c conn, t Table, columns []string, row []interface{}) error {
+	if len(columns) == 0 {
+		columns = t.Updates
+	}
+
+	index := make(map[string]int, len(t.Columns))
+	for i, col := range t.Columns {
+		index[col] = i
+	}
+	for _, key := range t.Keys {
+		delete(index, key)
+	}
+
+	assignments := make([]string, len(columns))
+	args := make([]interface{}, 0, len(columns)+len(t.Keys))
+	for i, col := range columns {
+		pos, ok := index[col]
+		if !ok {
+			return fmt.Errorf("SQLException: %s is not an updatable column of %s", col, t.Name)
+		}
+		args = append(args, row[pos])
+		assignments[i] = fmt.Sprintf("%s = %s", p.dialect.Quote(col), p.dialect.Param(len(args)))
+	}
+
+	conditions := make([]string, len(t.Keys))
+	for i, key := range t.Keys {
+		for pos, col := range t.Columns {
+			if col == key {
+				args = append(args, row[pos])
+			}
+		}
+		conditions[i] = fmt.Sprintf("%s = %s", p.dialect.Quote(key), p.dialect.Param(len(args)))
+	}
+
+	_, err := c.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET %s WHERE %s",
+		p.dialect.Quote(t.Name), strings.Join(assignments, ", "), strings.Join(conditions, " AND ")), args...)
+	return err
+}
+
+func flatten(rows [][]interface{}) []interface{} {
+	var args []interface{}
+	for _, row := range rows {
+		args = append(args, row...)
+	}
+	return args
+}
@@ -0,0 +1,235 @@
+package sqlsink
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+type fakeConn struct {
+	queries []string
+	args    [][]interface{}
+	fail    func(args []interface{}) bool
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	c.queries = append(c.queries, query)
+	c.args = append(c.args, args)
+	if c.fail != nil && c.fail(args) {
+		return nil, errors.New("rejected")
+	}
+	return nil, nil
+}
+
+func (c *fakeConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return nil
+}
+
+// testDialect - numbered placeholders and unix times, savepoints as configured
+type testDialect struct {
+	savepoints bool
+}
+
+func (testDialect) Param(n int) string                { return fmt.Sprintf("$%d", n) }
+func (testDialect) Quote(name string) string          { return `"` + name + `"` }
+func (d testDialect) OnConflict(t Table) string       { return OnConflict(d, t) }
+func (testDialect) ForUpdate() string                 { return "" }
+func (testDialect) MaxParams() int                    { return 65535 }
+func (testDialect) Time(at time.Time) interface{}     { return at.Unix() }
+func (testDialect) Unset() interface{}                { return nil }
+func (testDialect) Value(val interface{}) interface{} { return val }
+func (d testDialect) Savepoints() bool                { return d.savepoints }
+func (testDialect) Temporary(err error) bool          { return errors.Is(err, errLocked) }
+
+var errLocked = errors.New("locked")
+
+var testProvider = New(nil, testDialect{}, 0)
+
+// rejecting - fails every statement writing the value bad
+func rejecting() *fakeConn {
+	return &fakeConn{fail: func(args []interface{}) bool {
+		for _, arg := range args {
+			if arg == "bad" {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+func TestUpsertQuery(t *testing.T) {
+	expected := `INSERT INTO "ticket_metadata" ("ticket_id", "field_id", "raw_value", "transformed_value") ` +
+		`VALUES ($1, $2, $3, $4), ($5, $6, $7, $8) ON CONFLICT ("ticket_id", "field_id") ` +
+		`DO UPDATE SET "raw_value" = excluded."raw_value", "transformed_value" = excluded."transformed_value"`
+	if got := testProvider.upsertQuery(ticketFieldValuesTable, 2); got != expected {
+		t.Errorf("expected\n\t%s\ngot\n\t%s", expected, got)
+	}
+
+	if guarded := testProvider.upsertQuery(ticketAuditsTable, 1); !strings.HasSuffix(guarded, `WHERE excluded."audit_id" > "ticket_audit"."audit_id"`) {
+		t.Errorf("expected older audits to be ignored, got %s", guarded)
+	}
+}
+
+func TestUpsertBatches(t *testing.T) {
+	rows := make([][]interface{}, DEFAULT_BATCH_SIZE+1)
+	for i := range rows {
+		rows[i] = []interface{}{int64(i), "title"}
+	}
+
+	c := &fakeConn{}
+	if err := testProvider.upsert(context.Background(), c, ticketFieldsTable, rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.queries) != 2 || len(c.args[0]) != 2*DEFAULT_BATCH_SIZE || len(c.args[1]) != 2 {
+		t.Errorf("expected a full batch followed by a single row, got %d statements", len(c.queries))
+	}
+
+	c = &fakeConn{}
+	if err := New(nil, testDialect{}, 2).upsert(context.Background(), c, ticketFieldsTable, rows[:5]); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.queries) != 3 {
+		t.Errorf("expected the configured batch size to split 5 rows into 3 statements, got %d", len(c.queries))
+	}
+}
+
+func TestUpsertFailsOnRejectedRow(t *testing.T) {
+	rows := [][]interface{}{{int64(1), "a"}, {int64(2), "bad"}, {int64(3), "c"}}
+
+	c := rejecting()
+	err := testProvider.upsert(context.Background(), c, ticketFieldsTable, rows)
+	if err == nil || !strings.Contains(err.Error(), "failed to insert 2 into ticket_fields") {
+		t.Errorf("expected the rejected row to fail the upsert, got %v", err)
+	}
+	// the batch, then each row on its own up to the one at fault
+	if len(c.queries) != 3 {
+		t.Errorf("expected 3 statements, got %d", len(c.queries))
+	}
+}
+
+func TestUpsertRollsBackToSavepoints(t *testing.T) {
+	rows := [][]interface{}{{int64(1), "a"}, {int64(2), "bad"}, {int64(3), "c"}}
+
+	c := rejecting()
+	if err := New(nil, testDialect{savepoints: true}, 0).upsert(context.Background(), c, ticketFieldsTable, rows); err == nil {
+		t.Error("expected the rejected row to fail the upsert")
+	}
+
+	// each attempt runs under a savepoint, failures roll back to it rather than aborting the transaction
+	var inserts, rollbacks int
+	for _, qry := range c.queries {
+		switch {
+		case strings.HasPrefix(qry, "INSERT"):
+			inserts++
+		case strings.HasPrefix(qry, "ROLLBACK TO SAVEPOINT"):
+			rollbacks++
+		}
+	}
+	if inserts != 3 || rollbacks != 2 {
+		t.Errorf("expected 3 inserts and 2 rollbacks, got %d and %d: %q", inserts, rollbacks, c.queries)
+	}
+}
+
+func TestUpdateSetsOnlyNamedColumns(t *testing.T) {
+	row := []interface{}{int64(7), "subject", "open", 1, 2, 3, 4, 5, 6, 7, "1.0", "core", "p1", int64(30), int64(0)}
+
+	c := &fakeConn{}
+	if err := testProvider.update(context.Background(), c, ticketsTable, []string{"version", "priority"}, row); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `UPDATE "tickets" SET "version" = $1, "priority" = $2 WHERE "id" = $3`; c.queries[0] != expected {
+		t.Errorf("expected %s, got %s", expected, c.queries[0])
+	}
+	if args := c.args[0]; len(args) != 3 || args[0] != "1.0" || args[1] != "p1" || args[2] != int64(7) {
+		t.Errorf("unexpected arguments %v", args)
+	}
+
+	c = &fakeConn{}
+	if err := testProvider.update(context.Background(), c, ticketsTable, nil, row); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(c.queries[0], "version") {
+		t.Errorf("expected a plain refresh to leave enrichment columns be, got %s", c.queries[0])
+	}
+
+	for _, columns := range [][]string{{"id"}, {"missing"}} {
+		if err := testProvider.update(context.Background(), &fakeConn{}, ticketsTable, columns, row); err == nil {
+			t.Errorf("expected updating %v to fail", columns)
+		}
+	}
+}
+
+func TestImportAuditKeepsNewestPerTicket(t *testing.T) {
+	c := &fakeConn{}
+
+	change := func(id int64, value string) models.Audit {
+		return models.Audit{Id: id, Ticket_id: 1, Events: []models.Event{{Type: "Change", Field_name: "34347708", Value: value}}}
+	}
+	if err := testProvider.importAudit(context.Background(), c, []models.Audit{change(3, "newest"), change(2, "older")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.queries) != 1 || !strings.Contains(c.queries[0], "VALUES ($1, $2, $3, $4) ON") {
+		t.Fatalf("expected a single row, got %q", c.queries)
+	}
+	if args := c.args[0]; args[1] != int64(3) || args[3] != "newest" {
+		t.Errorf("expected the newest audit to be written, got %v", args)
+	}
+}
+
+func TestRowsEncodeTimesInTheDialect(t *testing.T) {
+	if row := testProvider.userRow(models.User{Id: 7, Email: "someone@example.com"}); row[3] != nil {
+		t.Errorf("expected a missing created_at to be written as NULL, got %v", row[3])
+	}
+
+	solved := time.Unix(1500000000, 0)
+	row := testProvider.ticketMetricRow(models.Ticket_metrics{Id: 1, Solved_at: solved})
+	if row[6] != solved.Unix() {
+		t.Errorf("expected the solved time in unix seconds, got %v", row[6])
+	}
+	if row = testProvider.ticketMetricRow(models.Ticket_metrics{Id: 1}); row[6] != nil {
+		t.Errorf("expected an unsolved ticket to be written as unset, got %v", row[6])
+	}
+}
+
+func TestStoredTime(t *testing.T) {
+	expected := time.Unix(1500000000, 0)
+	for _, src := range []interface{}{int64(1500000000), uint64(1500000000), []byte("1500000000"), expected} {
+		var at time.Time
+		if err := (storedTime{&at}).Scan(src); err != nil || !at.Equal(expected) {
+			t.Errorf("expected %v to scan as %s, got %s: %v", src, expected, at, err)
+		}
+	}
+
+	var at time.Time
+	if err := (storedTime{&at}).Scan(nil); err != nil || at.Unix() != 0 {
+		t.Errorf("expected NULL to scan as the epoch, got %s: %v", at, err)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	for _, err := range []error{
+		fmt.Errorf("SQLException: failed to insert 1 into groups: %w", errLocked),
+		fmt.Errorf("SQLException: failed to insert 1 into groups: %w", driver.ErrBadConn),
+		&net.OpError{Op: "dial", Err: errors.New("connection refused")},
+	} {
+		var temporary interface{ Temporary() bool }
+		if !errors.As(testProvider.classify(err), &temporary) || !temporary.Temporary() {
+			t.Errorf("expected %v to be temporary", err)
+		}
+	}
+
+	for _, err := range []error{errors.New("rejected"), fmt.Errorf("SQLException: %w", context.Canceled)} {
+		if _, ok := testProvider.classify(err).(*TemporaryError); ok {
+			t.Errorf("expected %v not to be temporary", err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package zendesk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// same layout as the pipeline's run ids, sorts in the order exports started
+const archiveTimeFormat = "20060102T150405.000000000Z"
+
+// Archive - object storage receiving the raw pages of every export, see the archive section of the README
+type Archive interface {
+	Put(ctx context.Context, key string, body []byte) error
+}
+
+// Archives - puts every page to each archive in turn, the first failure is returned
+type Archives []Archive
+
+func (a Archives) Put(ctx context.Context, key string, body []byte) error {
+	for _, archive := range a {
+		if err := archive.Put(ctx, key, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ArchiveReader - archive pages are replayed from, see ReplayProvider
+type ArchiveReader interface {
+	// List - keys starting with prefix in lexical order
+	List(ctx context.Context, prefix string) ([]string, error)
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// Directory - an archive on disk, each key a file relative to the directory. A bucket copied with e.g.
+// aws s3 sync or mc mirror can be replayed from here without access to the bucket.
+type Directory string
+
+func (d Directory) Put(ctx context.Context, key string, body []byte) error {
+	file := filepath.Join(string(d), filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(file+".tmp", body, 0644); err != nil {
+		return err
+	}
+	return os.Rename(file+".tmp", file)
+}
+
+func (d Directory) Get(ctx context.Context, key string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(string(d), filepath.FromSlash(key)))
+}
+
+func (d Directory) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	root := filepath.Join(string(d), filepath.FromSlash(path.Dir(prefix)))
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || info.IsDir() || strings.HasSuffix(file, ".tmp") {
+			return err
+		}
+		rel, err := filepath.Rel(string(d), file)
+		if key := filepath.ToSlash(rel); err == nil && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return err
+	})
+
+	sort.Strings(keys)
+	return keys, err
+}
+
+// ArchiveError - a page was fetched but could not be archived
+type ArchiveError struct {
+	Key string
+	Err error
+}
+
+func (e *ArchiveError) Error() string {
+	return fmt.Sprintf("failed to archive %s: %s", e.Key, e.Err)
+}
+
+// Temporary - the page is fetched and archived again by the next run, worth it only when the store reports a
+// failure it expects to recover from, e.g. throttling
+func (e *ArchiveError) Temporary() bool {
+	temporary, ok := e.Err.(interface {
+		Temporary() bool
+	})
+	return ok && temporary.Temporary()
+}
+
+// archiveKey - <resource>/<export start>/<page number>_<checkpoint>.json.gz, keys of a resource sort in the order
+// their pages were fetched. The checkpoint is where an export resumes after the page, offset pages have none.
+func archiveKey(resource string, started time.Time, seq int, cursor string) string {
+	key := fmt.Sprintf("%s/%s/%06d", resource, started.UTC().Format(archiveTimeFormat), seq)
+	if cursor != "" {
+		key += "_" + url.QueryEscape(cursor)
+	}
+	return key + ".json.gz"
+}
+
+// resourceOf - the resource path lists, e.g. tickets for ./incremental/tickets/cursor.json?start_time=0
+func resourceOf(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	path = strings.TrimPrefix(path, "./")
+	path = strings.TrimPrefix(path, "incremental/")
+	path = strings.TrimSuffix(path, ".json")
+	path = strings.TrimSuffix(path, "/cursor")
+	return path
+}
+
+// archivePage - stores the page as received, gzip compressed
+func (r *ZDProvider) archivePage(ctx context.Context, key string, body []byte) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return &ArchiveError{key, err}
+	}
+	if err := zw.Close(); err != nil {
+		return &ArchiveError{key, err}
+	}
+
+	if err := r.archive.Put(ctx, key, buf.Bytes()); err != nil {
+		return &ArchiveError{key, err}
+	}
+	return nil
+}
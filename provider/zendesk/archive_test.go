@@ -0,0 +1,111 @@
+package zendesk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+// memoryArchive - keeps archived pages in the order they were put
+type memoryArchive struct {
+	keys   []string
+	bodies [][]byte
+	err    error
+}
+
+func (a *memoryArchive) Put(ctx context.Context, key string, body []byte) error {
+	if a.err != nil {
+		return a.err
+	}
+	a.keys = append(a.keys, key)
+	a.bodies = append(a.bodies, body)
+	return nil
+}
+
+func TestExportArchivesEveryPage(t *testing.T) {
+	first := `{"tickets": [{"id": 1}], "after_cursor": "c1", "after_url": "{server}/api/v2/incremental/tickets/cursor.json?cursor=c1", "end_of_stream": false}`
+	r := testProvider(t, map[string]string{
+		"/api/v2/incremental/tickets/cursor.json?start_time=0&include=" + ticketSideloads: first,
+		"/api/v2/incremental/tickets/cursor.json?cursor=c1":                               `{"tickets": [{"id": 2}], "after_cursor": "c/2", "end_of_stream": true}`,
+	})
+	archive := &memoryArchive{}
+	r.ArchiveTo(archive)
+
+	if _, err := r.ExportTickets(context.Background(), 0, "", func(models.Ticket_page, string) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(archive.keys) != 2 {
+		t.Fatalf("expected both pages to be archived, got %v", archive.keys)
+	}
+	if !strings.HasPrefix(archive.keys[0], "tickets/") || !strings.HasSuffix(archive.keys[0], "/000001_c1.json.gz") ||
+		!strings.HasSuffix(archive.keys[1], "/000002_c%2F2.json.gz") {
+		t.Errorf("expected keys by resource, page and checkpoint, got %v", archive.keys)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(archive.bodies[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := ioutil.ReadAll(zr); !strings.Contains(string(raw), `"after_cursor": "c1"`) {
+		t.Errorf("expected the page as received, got %s", raw)
+	}
+}
+
+// throttledError - a failure the store expects to recover from
+type throttledError struct{}
+
+func (throttledError) Error() string   { return "slow down" }
+func (throttledError) Temporary() bool { return true }
+
+func TestArchiveFailureStopsExport(t *testing.T) {
+	r := testProvider(t, map[string]string{
+		"/api/v2/groups.json": `{"groups": [{"id": 1}], "next_page": null}`,
+	})
+	r.ArchiveTo(&memoryArchive{err: errors.New("access denied")})
+
+	processed := false
+	_, err := r.ListGroups(context.Background(), func([]models.Group) error {
+		processed = true
+		return nil
+	})
+	if archiveErr, ok := err.(*ArchiveError); !ok || archiveErr.Temporary() || !strings.HasPrefix(archiveErr.Key, "groups/") {
+		t.Errorf("expected an archive error which is not temporary, got %v", err)
+	}
+	if processed {
+		t.Error("expected a page which was not archived not to be processed")
+	}
+}
+
+func TestArchiveErrorTemporary(t *testing.T) {
+	for _, c := range []struct {
+		err       error
+		temporary bool
+	}{
+		{throttledError{}, true},
+		{errors.New("access denied"), false},
+	} {
+		if got := (&ArchiveError{"groups/1.json.gz", c.err}).Temporary(); got != c.temporary {
+			t.Errorf("expected Temporary() of %v to be %v", c.err, c.temporary)
+		}
+	}
+}
+
+func TestResourceOf(t *testing.T) {
+	for path, resource := range map[string]string{
+		"./incremental/tickets/cursor.json?start_time=0": "tickets",
+		"./incremental/organizations.json?start_time=0":  "organizations",
+		"./ticket_audits.json?cursor=":                   "ticket_audits",
+		"./ticket_fields.json":                           "ticket_fields",
+	} {
+		if got := resourceOf(path); got != resource {
+			t.Errorf("expected %s for %s, got %s", resource, path, got)
+		}
+	}
+}
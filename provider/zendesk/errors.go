@@ -0,0 +1,52 @@
+package zendesk
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// TransportError - the request failed before zendesk produced a response
+type TransportError struct {
+	URL string
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("unable to fetch from %s: %s", e.URL, e.Err)
+}
+
+// Temporary - network failures are worth retrying
+func (e *TransportError) Temporary() bool {
+	return true
+}
+
+// StatusError - zendesk responded with something other than 200 OK
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unable to fetch from %s: %s: %s", e.URL, e.Status, e.Body)
+}
+
+// Temporary - rate limiting and server side failures are worth retrying, anything else will fail again
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// DecodeError - the response body could not be read or did not match the expected payload
+type DecodeError struct {
+	URL string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode response from %s: %s", e.URL, e.Err)
+}
+
+func (e *DecodeError) Temporary() bool {
+	return false
+}
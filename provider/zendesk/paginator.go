@@ -0,0 +1,229 @@
+package zendesk
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Pagination - the scheme a zendesk endpoint uses to link its pages
+type Pagination int
+
+const (
+	// OFFSET - follow next_page until it is empty
+	OFFSET Pagination = iota
+	// INCREMENTAL - time based incremental export, follow next_page while pages are full, resume from end_time
+	INCREMENTAL
+	// CURSOR - walk backwards through before_url until before_cursor is empty, resume from after_cursor
+	CURSOR
+	// INCREMENTAL_CURSOR - cursor based incremental export, follow after_url until end_of_stream, resume from the last after_cursor
+	INCREMENTAL_CURSOR
+)
+
+// incremental exports return at most this many records per page
+const incrementalPageSize = 1000
+
+// pager - pagination metadata shared by every list response
+type pager struct {
+	Next          string `json:"next_page"`
+	Previous      string `json:"previous_page"`
+	End           int64  `json:"end_time"`
+	Count         int64  `json:"count"`
+	Before_URL    string `json:"before_url"`
+	Before_Cursor string `json:"before_cursor"`
+	After_URL     string `json:"after_url"`
+	After_Cursor  string `json:"after_cursor"`
+	End_of_stream bool   `json:"end_of_stream"`
+}
+
+// Pages - iterator over the pages of a paginated resource
+//
+//	pages := r.Paginate("./groups.json", OFFSET)
+//	for pages.Next() {
+//		pages.Decode(&payload)
+//	}
+type Pages struct {
+	ctx     context.Context
+	r       *ZDProvider
+	style   Pagination
+	next    *url.URL
+	page    *url.URL
+	current pager
+	body    []byte
+	cursor  string
+	err     error
+
+	// names the pages in the archive, if any
+	resource string
+	started  time.Time
+	seq      int
+}
+
+// Paginate - iterates over path, relative to the api root, following links according to style until ctx is cancelled
+func (r *ZDProvider) Paginate(ctx context.Context, path string, style Pagination) *Pages {
+	next, err := r.base.Parse(path)
+	if err != nil {
+		err = &TransportError{path, err}
+	}
+
+	return &Pages{
+		ctx:      ctx,
+		r:        r,
+		style:    style,
+		next:     next,
+		err:      err,
+		resource: resourceOf(path),
+		started:  time.Now(),
+	}
+}
+
+// Next - fetches the next page, returns false once the resource is exhausted or a request fails
+func (p *Pages) Next() bool {
+	if p.next == nil || p.err != nil {
+		return false
+	}
+
+	p.page, p.next = p.next, nil
+
+	if p.body, p.err = p.r.fetch(p.ctx, p.page); p.err != nil {
+		return false
+	}
+
+	p.current = pager{}
+	if err := json.Unmarshal(p.body, &p.current); err != nil {
+		p.err = &DecodeError{p.page.String(), err}
+		return false
+	}
+
+	var link string
+	switch p.style {
+	case OFFSET:
+		link = p.current.Next
+	case INCREMENTAL:
+		p.cursor = strconv.FormatInt(p.current.End, 10)
+		if p.current.Count >= incrementalPageSize {
+			link = p.current.Next
+		}
+	case CURSOR:
+		// after_cursor of the first (newest) page is where the next run resumes
+		if p.cursor == "" {
+			p.cursor = p.current.After_Cursor
+		}
+		if p.current.Before_Cursor != "" {
+			link = p.current.Before_URL
+		}
+	case INCREMENTAL_CURSOR:
+		if p.current.After_Cursor != "" {
+			p.cursor = p.current.After_Cursor
+		}
+		if !p.current.End_of_stream {
+			link = p.current.After_URL
+		}
+	}
+
+	if link != "" {
+		p.next, _ = p.page.Parse(link)
+	}
+
+	// archived before it is processed so the archive holds every page a sink may have seen
+	if p.r.archive != nil {
+		p.seq++
+		if p.err = p.r.archivePage(p.ctx, archiveKey(p.resource, p.started, p.seq, p.cursor), p.body); p.err != nil {
+			p.next = nil
+			return false
+		}
+	}
+	return true
+}
+
+// Decode - unmarshals the current page into payload, a failure also stops iteration
+func (p *Pages) Decode(payload interface{}) error {
+	if err := json.Unmarshal(p.body, payload); err != nil {
+		p.err = &DecodeError{p.page.String(), err}
+		p.next = nil
+	}
+	return p.err
+}
+
+// Err - the error which stopped iteration, nil if every page was fetched
+func (p *Pages) Err() error {
+	return p.err
+}
+
+// Cursor - where a subsequent export should resume, end_time for INCREMENTAL and after_cursor otherwise
+func (p *Pages) Cursor() string {
+	return p.cursor
+}
+
+// fetch - reads the body of a successful response from target, retrying rate limited and failed requests
+func (r *ZDProvider) fetch(ctx context.Context, target *url.URL) ([]byte, error) {
+	limit := family(target)
+	request, err := r.newRequest(ctx, target)
+	if err != nil {
+		return nil, &TransportError{target.String(), err}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := sleep(ctx, r.limiter.reserve(limit)); err != nil {
+			return nil, err
+		}
+
+		body, retry, delay, err := r.attempt(request, attempt)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !retry {
+			return body, err
+		}
+
+		log.Printf("WARN: Retrying %s in %s: %s", request.URL, delay, err)
+		if err = sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *ZDProvider) attempt(request *http.Request, attempt int) (body []byte, retry bool, delay time.Duration, err error) {
+	resp, err := r.client.Do(request)
+	if err != nil {
+		retry, delay = r.limiter.failed(attempt)
+		return nil, retry, delay, &TransportError{request.URL.String(), err}
+	}
+	defer resp.Body.Close()
+
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		retry, delay = r.limiter.failed(attempt)
+		return nil, retry, delay, &TransportError{request.URL.String(), err}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		retry, delay = r.limiter.observe(resp, attempt)
+		return nil, retry, delay, &StatusError{request.URL.String(), resp.StatusCode, resp.Status, body}
+	}
+
+	r.limiter.observe(resp, attempt)
+	return body, false, 0, nil
+}
+
+// sleep - waits for d, returning early with ctx's error if it is cancelled first
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,304 @@
+package zendesk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+// testProvider - provider pointed at a local server replying with pages keyed by request URI
+func testProvider(t *testing.T, pages map[string]string) *ZDProvider {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, ok := pages[req.URL.RequestURI()]
+		if !ok {
+			t.Errorf("unexpected request for %s", req.URL.RequestURI())
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprint(w, strings.Replace(body, "{server}", srv.URL, -1))
+	}))
+	t.Cleanup(srv.Close)
+
+	return testOpen(srv)
+}
+
+// testOpen - provider pointed at srv without any meaningful rate limiting
+func testOpen(srv *httptest.Server) *ZDProvider {
+	r := Open(srv.Client(), &ZendeskConfig{
+		Subdomain: "test",
+		RateLimit: &RateLimitConfig{
+			RequestsPerMinute: map[string]int{DEFAULT_FAMILY: 60000, INCREMENTAL_FAMILY: 60000},
+			MaxRetries:        2,
+		},
+	})
+	r.base, _ = url.Parse(srv.URL + "/api/v2/")
+	r.limiter.backoff = time.Millisecond
+	return r
+}
+
+func collect(t *testing.T, pages *Pages) (ids []int64) {
+	var payload struct {
+		Items []struct {
+			Id int64 `json:"id"`
+		} `json:"items"`
+	}
+
+	for pages.Next() {
+		payload.Items = nil
+		if err := pages.Decode(&payload); err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range payload.Items {
+			ids = append(ids, item.Id)
+		}
+	}
+	return ids
+}
+
+func TestOffsetPagination(t *testing.T) {
+	r := testProvider(t, map[string]string{
+		"/api/v2/items.json":        `{"items": [{"id": 1}, {"id": 2}], "next_page": "{server}/api/v2/items.json?page=2"}`,
+		"/api/v2/items.json?page=2": `{"items": [{"id": 3}], "next_page": null}`,
+	})
+
+	pages := r.Paginate(context.Background(), "./items.json", OFFSET)
+	if ids := collect(t, pages); len(ids) != 3 || ids[2] != 3 {
+		t.Errorf("expected ids [1 2 3], got %v", ids)
+	}
+}
+
+func TestIncrementalPagination(t *testing.T) {
+	full := `{"items": [{"id": 1}], "count": 1000, "end_time": 200, "next_page": "{server}/api/v2/items.json?start_time=200"}`
+	r := testProvider(t, map[string]string{
+		"/api/v2/items.json?start_time=100": full,
+		"/api/v2/items.json?start_time=200": `{"items": [{"id": 2}], "count": 1, "end_time": 300, "next_page": "ignored"}`,
+	})
+
+	pages := r.Paginate(context.Background(), "./items.json?start_time=100", INCREMENTAL)
+	if ids := collect(t, pages); len(ids) != 2 {
+		t.Errorf("expected 2 ids, got %v", ids)
+	}
+	if pages.Cursor() != "300" {
+		t.Errorf("expected cursor 300, got %s", pages.Cursor())
+	}
+}
+
+func TestCursorPagination(t *testing.T) {
+	r := testProvider(t, map[string]string{
+		"/api/v2/items.json?cursor=": `{"items": [{"id": 9}], "after_cursor": "newest",
+			"before_cursor": "b1", "before_url": "{server}/api/v2/items.json?cursor=b1"}`,
+		"/api/v2/items.json?cursor=b1": `{"items": [{"id": 5}], "after_cursor": "older", "before_cursor": null}`,
+	})
+
+	pages := r.Paginate(context.Background(), "./items.json?cursor=", CURSOR)
+	if ids := collect(t, pages); len(ids) != 2 || ids[1] != 5 {
+		t.Errorf("expected ids [9 5], got %v", ids)
+	}
+	if pages.Cursor() != "newest" {
+		t.Errorf("expected cursor newest, got %s", pages.Cursor())
+	}
+}
+
+func TestIncrementalCursorPagination(t *testing.T) {
+	r := testProvider(t, map[string]string{
+		"/api/v2/items.json?cursor=c0": `{"items": [{"id": 1}], "after_cursor": "c1",
+			"after_url": "{server}/api/v2/items.json?cursor=c1", "end_of_stream": false}`,
+		"/api/v2/items.json?cursor=c1": `{"items": [{"id": 2}], "after_cursor": "c2",
+			"after_url": "{server}/api/v2/items.json?cursor=c2", "end_of_stream": true}`,
+	})
+
+	pages := r.Paginate(context.Background(), "./items.json?cursor=c0", INCREMENTAL_CURSOR)
+	if ids := collect(t, pages); len(ids) != 2 || ids[1] != 2 {
+		t.Errorf("expected ids [1 2], got %v", ids)
+	}
+	if pages.Cursor() != "c2" {
+		t.Errorf("expected cursor c2, got %s", pages.Cursor())
+	}
+}
+
+func TestExportUsersResumesFromCursor(t *testing.T) {
+	r := testProvider(t, map[string]string{
+		"/api/v2/incremental/users/cursor.json?cursor=a%2Bb": `{"users": [{"id": 5}], "after_cursor": "", "end_of_stream": true}`,
+	})
+
+	last, err := r.ExportUsers(context.Background(), 0, "a+b", func([]models.User, string) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != "a+b" {
+		t.Errorf("expected the original cursor when none is reported, got %q", last)
+	}
+}
+
+func TestExportOrganizationsReturnsLastProcessedPage(t *testing.T) {
+	r := testProvider(t, map[string]string{
+		"/api/v2/incremental/organizations.json?start_time=100": `{"organizations": [{"id": 1}], "count": 1000,
+			"end_time": 200, "next_page": "{server}/api/v2/incremental/organizations.json?start_time=200"}`,
+		"/api/v2/incremental/organizations.json?start_time=200": `{"organizations": [{"id": 2}], "count": 1, "end_time": 300}`,
+	})
+
+	failure := fmt.Errorf("import failed")
+	last, err := r.ExportOrganizations(context.Background(), 100, func(page []models.Organization, next int64) error {
+		if next == 300 {
+			return failure
+		}
+		return nil
+	})
+	if err != failure || last != 200 {
+		t.Errorf("expected to resume after the first page, got %d %v", last, err)
+	}
+}
+
+func TestExportStopsOnStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, `{"error": "Unavailable"}`, http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	r := testOpen(srv)
+
+	last, err := r.ExportTickets(context.Background(), 100, "resume", func(models.Ticket_page, string) error {
+		t.Error("no pages should be processed")
+		return nil
+	})
+
+	statusErr, ok := err.(*StatusError)
+	if !ok || statusErr.StatusCode != http.StatusServiceUnavailable || !statusErr.Temporary() {
+		t.Fatalf("expected a temporary *StatusError, got %#v", err)
+	}
+	if last != "resume" {
+		t.Errorf("expected export to resume from its original cursor, got %q", last)
+	}
+}
+
+func TestConcurrentCalls(t *testing.T) {
+	r := testProvider(t, map[string]string{
+		"/api/v2/groups.json":        `{"groups": [{"id": 1}], "next_page": "{server}/api/v2/groups.json?page=2"}`,
+		"/api/v2/groups.json?page=2": `{"groups": [{"id": 2}], "next_page": null}`,
+		"/api/v2/tickets/7.json":     `{"ticket": {"id": 7}}`,
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			last, err := r.ListGroups(context.Background(), func([]models.Group) error { return nil })
+			if err != nil || last != 2 {
+				t.Errorf("expected last group 2, got %d: %v", last, err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			err := r.GetTicket(context.Background(), 7, func(ticket models.Ticket) error {
+				if ticket.Id != 7 {
+					t.Errorf("expected ticket 7, got %d", ticket.Id)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestExportTicketMetrics(t *testing.T) {
+	var served int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&served, 1)
+		var id int64
+		if _, err := fmt.Sscanf(req.URL.Path, "/api/v2/tickets/%d/metrics.json", &id); err != nil || id == 404 {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprintf(w, `{"ticket_metric": {"id": %d, "ticket_id": %d}}`, id+1000, id)
+	}))
+	defer srv.Close()
+
+	r := testOpen(srv)
+	r.workers = 3
+
+	tickets := []int64{11, 404, 7, 42, 3}
+	var metrics []models.Ticket_metrics
+	last, err := r.ExportTicketMetrics(context.Background(), tickets, func(page []models.Ticket_metrics) error {
+		metrics = append(metrics, page...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if last != 4 || len(metrics) != 4 {
+		t.Fatalf("expected 4 metrics skipping the deleted ticket, got %d: %v", last, metrics)
+	}
+	for i, id := range []int64{11, 7, 42, 3} {
+		if metrics[i].Ticket_id != id {
+			t.Errorf("expected metrics in ticket order, got ticket %d at %d", metrics[i].Ticket_id, i)
+		}
+	}
+	if served != int32(len(tickets)) {
+		t.Errorf("expected one request per ticket, got %d", served)
+	}
+}
+
+func TestExportTicketMetricsStopsOnError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, `{"error": "Forbidden"}`, http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	r := testOpen(srv)
+	_, err := r.ExportTicketMetrics(context.Background(), []int64{1, 2, 3, 4, 5, 6}, func([]models.Ticket_metrics) error {
+		t.Error("no metrics should be processed")
+		return nil
+	})
+
+	if statusErr, ok := err.(*StatusError); !ok || statusErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected a *StatusError, got %#v", err)
+	}
+}
+
+func TestExportTicketsSideloads(t *testing.T) {
+	r := testProvider(t, map[string]string{
+		"/api/v2/incremental/tickets/cursor.json?start_time=100&include=metric_sets,users,groups,organizations": `{
+			"tickets": [{"id": 1, "requester_id": 5, "organization_id": 9, "group_id": 3}],
+			"metric_sets": [{"id": 40, "ticket_id": 1}],
+			"users": [{"id": 5}], "groups": [{"id": 3}], "organizations": [{"id": 9}],
+			"after_cursor": "c1", "end_of_stream": true}`,
+	})
+
+	var pages []models.Ticket_page
+	last, err := r.ExportTickets(context.Background(), 100, "", func(page models.Ticket_page, next string) error {
+		if next != "c1" {
+			t.Errorf("expected the page to resume from c1, got %q", next)
+		}
+		pages = append(pages, page)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if last != "c1" || len(pages) != 1 {
+		t.Fatalf("expected a single page ending at c1, got %d pages ending at %q", len(pages), last)
+	}
+	page := pages[0]
+	if len(page.Tickets) != 1 || len(page.Metric_sets) != 1 || page.Metric_sets[0].Ticket_id != 1 {
+		t.Errorf("expected the ticket and its metrics, got %+v", page)
+	}
+	if len(page.Users) != 1 || len(page.Groups) != 1 || len(page.Organizations) != 1 {
+		t.Errorf("expected sideloaded users, groups and organizations, got %+v", page)
+	}
+}
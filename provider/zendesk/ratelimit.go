@@ -0,0 +1,161 @@
+package zendesk
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// endpoint families, zendesk limits each independently
+const (
+	DEFAULT_FAMILY     = "default"
+	INCREMENTAL_FAMILY = "incremental"
+)
+
+const (
+	defaultRetries = 5
+	defaultBackoff = time.Second
+	maxBackoff     = time.Minute
+)
+
+// per minute budgets matching zendesk's lowest plan, incremental exports are limited separately
+var defaultBudgets = map[string]int{
+	DEFAULT_FAMILY:     200,
+	INCREMENTAL_FAMILY: 10,
+}
+
+// RateLimitConfig - client side limits, zero values fall back to defaults
+type RateLimitConfig struct {
+	RequestsPerMinute map[string]int `json:"requests_per_minute"`
+	MaxRetries        int            `json:"max_retries"`
+}
+
+// limiter - paces requests per endpoint family and decides when failed requests are retried
+type limiter struct {
+	sync.Mutex
+	spacing    map[string]time.Duration
+	next       map[string]time.Time
+	resume     time.Time
+	remaining  int
+	maxRetries int
+	backoff    time.Duration
+}
+
+func newLimiter(conf *RateLimitConfig) *limiter {
+	l := &limiter{
+		spacing:    make(map[string]time.Duration),
+		next:       make(map[string]time.Time),
+		remaining:  -1,
+		maxRetries: defaultRetries,
+		backoff:    defaultBackoff,
+	}
+
+	budgets := defaultBudgets
+	if conf != nil {
+		if conf.MaxRetries > 0 {
+			l.maxRetries = conf.MaxRetries
+		}
+		budgets = make(map[string]int)
+		for family, rpm := range defaultBudgets {
+			budgets[family] = rpm
+		}
+		for family, rpm := range conf.RequestsPerMinute {
+			budgets[family] = rpm
+		}
+	}
+
+	for family, rpm := range budgets {
+		if rpm > 0 {
+			l.spacing[family] = time.Minute / time.Duration(rpm)
+		}
+	}
+	return l
+}
+
+// family - incremental exports share their own budget, everything else is limited account wide
+func family(u *url.URL) string {
+	if strings.Contains(u.Path, "/incremental/") {
+		return INCREMENTAL_FAMILY
+	}
+	return DEFAULT_FAMILY
+}
+
+// reserve - claims the next request slot for family, returning how long to wait before using it
+func (l *limiter) reserve(family string) time.Duration {
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	at := l.next[family]
+	if at.Before(now) {
+		at = now
+	}
+	if at.Before(l.resume) {
+		at = l.resume
+	}
+
+	spacing := l.spacing[family]
+	// running low, spread what is left over the next minute
+	if l.remaining >= 0 && l.remaining < 10 {
+		if adaptive := time.Minute / time.Duration(l.remaining+1); adaptive > spacing {
+			spacing = adaptive
+		}
+	}
+	l.next[family] = at.Add(spacing)
+
+	return at.Sub(now)
+}
+
+// observe - records the quota reported by zendesk and decides whether resp warrants a retry after delay
+func (l *limiter) observe(resp *http.Response, attempt int) (retry bool, delay time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Remaining")); err == nil {
+		l.remaining = remaining
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		var ok bool
+		if delay, ok = retryAfter(resp.Header.Get("Retry-After")); !ok {
+			delay = l.backoffFor(attempt)
+		}
+		// every request is refused until then, hold back the other families too
+		l.resume = time.Now().Add(delay)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		delay = l.backoffFor(attempt)
+	default:
+		return false, 0
+	}
+
+	return attempt < l.maxRetries, delay
+}
+
+// failed - decides whether a request which produced no response warrants a retry after delay
+func (l *limiter) failed(attempt int) (retry bool, delay time.Duration) {
+	return attempt < l.maxRetries, l.backoffFor(attempt)
+}
+
+// backoffFor - exponential backoff, capped at maxBackoff
+func (l *limiter) backoffFor(attempt int) time.Duration {
+	delay := l.backoff << uint(attempt)
+	if delay <= 0 || delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
+
+// retryAfter - parses either form of the Retry-After header, seconds or an HTTP date
+func retryAfter(val string) (time.Duration, bool) {
+	if seconds, err := strconv.Atoi(val); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(val); err == nil {
+		return time.Until(at), true
+	}
+	return 0, false
+}
@@ -0,0 +1,103 @@
+package zendesk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+func TestRetryAfterIsHonoured(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.Header().Set("X-Rate-Limit-Remaining", "42")
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"groups": [{"id": 7}]}`)
+	}))
+	defer srv.Close()
+
+	r := testOpen(srv)
+	r.limiter.backoff = time.Hour
+
+	last, err := r.ListGroups(context.Background(), func([]models.Group) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != 7 || calls != 2 {
+		t.Errorf("expected group 7 after 2 calls, got %d after %d", last, calls)
+	}
+	if r.limiter.remaining != 42 {
+		t.Errorf("expected 42 remaining requests, got %d", r.limiter.remaining)
+	}
+}
+
+func TestServerErrorsExhaustRetries(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	_, err := testOpen(srv).ListGroups(context.Background(), func([]models.Group) error { return nil })
+	if statusErr, ok := err.(*StatusError); !ok || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected a *StatusError, got %#v", err)
+	}
+	// initial attempt plus MaxRetries
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	if _, err := testOpen(srv).ListGroups(context.Background(), func([]models.Group) error { return nil }); err == nil || calls != 1 {
+		t.Errorf("expected a single failed call, got %d calls and %v", calls, err)
+	}
+}
+
+func TestBudgetSpacesRequests(t *testing.T) {
+	l := newLimiter(&RateLimitConfig{RequestsPerMinute: map[string]int{INCREMENTAL_FAMILY: 60}})
+
+	if wait := l.reserve(INCREMENTAL_FAMILY); wait != 0 {
+		t.Errorf("expected the first request to go immediately, waited %s", wait)
+	}
+	if wait := l.reserve(INCREMENTAL_FAMILY); wait < 900*time.Millisecond {
+		t.Errorf("expected the second request to wait about a second, waited %s", wait)
+	}
+	if wait := l.reserve(DEFAULT_FAMILY); wait != 0 {
+		t.Errorf("expected families to be limited independently, waited %s", wait)
+	}
+}
+
+func TestCancellationInterruptsBackoff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	r := testOpen(srv)
+	r.limiter.backoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := r.ListGroups(ctx, func([]models.Group) error { return nil }); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
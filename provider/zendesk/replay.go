@@ -0,0 +1,299 @@
+package zendesk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+// ReplayProvider - Source which reads the pages archived by a ZDProvider rather than fetching them, the pages of
+// every export of a resource are replayed in the order they were fetched. Incremental exports resume after the
+// archived page which led to their checkpoint, so a reset checkpoint replays the whole archive.
+// Safe for concurrent use.
+type ReplayProvider struct {
+	archive ArchiveReader
+	// receives every page replayed, if any
+	forward Archive
+}
+
+// archivedPage - key of an archived page and the checkpoint an export resumed from after it
+type archivedPage struct {
+	key        string
+	checkpoint string
+}
+
+func Replay(archive ArchiveReader) *ReplayProvider {
+	return &ReplayProvider{archive: archive}
+}
+
+// ArchiveTo - puts every page replayed from here on to a as it was archived, as ZDProvider.ArchiveTo does the
+// pages it fetches
+func (r *ReplayProvider) ArchiveTo(a Archive) {
+	r.forward = a
+}
+
+// pages - archived pages of resource as named by resourceOf, in the order they were fetched
+func (r *ReplayProvider) pages(ctx context.Context, resource string) ([]archivedPage, error) {
+	keys, err := r.archive.List(ctx, resource+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]archivedPage, 0, len(keys))
+	for _, key := range keys {
+		name := strings.TrimSuffix(path.Base(key), ".json.gz")
+		if name == path.Base(key) {
+			log.Printf("WARN: Skipping %s, not an archived page", key)
+			continue
+		}
+
+		page := archivedPage{key: key}
+		if i := strings.IndexByte(name, '_'); i >= 0 {
+			if page.checkpoint, err = url.QueryUnescape(name[i+1:]); err != nil {
+				return nil, &DecodeError{key, err}
+			}
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// decode - unmarshals the archived page into payload as Pages.Decode does the page as fetched
+func (r *ReplayProvider) decode(ctx context.Context, key string, payload interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	raw, err := r.archive.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if r.forward != nil {
+		if err = r.forward.Put(ctx, key, raw); err != nil {
+			return &ArchiveError{key, err}
+		}
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return &DecodeError{key, err}
+	}
+	body, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return &DecodeError{key, err}
+	}
+
+	if err = json.Unmarshal(body, payload); err != nil {
+		return &DecodeError{key, err}
+	}
+	return nil
+}
+
+// resumeAfter - pages following the last one which led to cursor, all of them when there is no cursor yet
+func resumeAfter(pages []archivedPage, resource string, cursor string) ([]archivedPage, error) {
+	if cursor == "" {
+		return pages, nil
+	}
+	for i := len(pages) - 1; i >= 0; i-- {
+		if pages[i].checkpoint == cursor {
+			return pages[i+1:], nil
+		}
+	}
+	return nil, fmt.Errorf("cursor %q was not archived with any %s page, reset the checkpoint to replay them all", cursor, resource)
+}
+
+func (r *ReplayProvider) ListTicketFields(ctx context.Context, process func([]models.Ticket_field) error) (last int64, err error) {
+	var rezponze struct {
+		Payload []models.Ticket_field `json:"ticket_fields"`
+	}
+
+	pages, err := r.pages(ctx, "ticket_fields")
+	if err != nil {
+		return last, err
+	}
+	for _, page := range pages {
+		rezponze.Payload = nil
+		if err = r.decode(ctx, page.key, &rezponze); err != nil {
+			return last, err
+		}
+
+		if err = process(rezponze.Payload); err != nil {
+			return last, err
+		}
+		for _, e := range rezponze.Payload {
+			if e.Id > last {
+				last = e.Id
+			}
+		}
+	}
+	return last, nil
+}
+
+func (r *ReplayProvider) ListGroups(ctx context.Context, process func([]models.Group) error) (last int64, err error) {
+	var rezponze struct {
+		Payload []models.Group `json:"groups"`
+	}
+
+	pages, err := r.pages(ctx, "groups")
+	if err != nil {
+		return last, err
+	}
+	for _, page := range pages {
+		rezponze.Payload = nil
+		if err = r.decode(ctx, page.key, &rezponze); err != nil {
+			return last, err
+		}
+
+		if err = process(rezponze.Payload); err != nil {
+			return last, err
+		}
+		for _, e := range rezponze.Payload {
+			if e.Id > last {
+				last = e.Id
+			}
+		}
+	}
+	return last, nil
+}
+
+// ExportOrganizations - pages whose end time is no later than since have been replayed already
+func (r *ReplayProvider) ExportOrganizations(ctx context.Context, since int64, process func(page []models.Organization, next int64) error) (last int64, err error) {
+	var rezponze struct {
+		Payload []models.Organization `json:"organizations"`
+	}
+
+	pages, err := r.pages(ctx, "organizations")
+	if err != nil {
+		return since, err
+	}
+	last = since
+	for _, page := range pages {
+		var next int64
+		if next, err = strconv.ParseInt(page.checkpoint, 10, 64); err != nil {
+			return last, &DecodeError{page.key, err}
+		}
+		if next <= since {
+			continue
+		}
+
+		rezponze.Payload = nil
+		if err = r.decode(ctx, page.key, &rezponze); err != nil {
+			return last, err
+		}
+		if err = process(rezponze.Payload, next); err != nil {
+			return last, err
+		}
+		last = next
+	}
+	return last, nil
+}
+
+// ExportUsers - since is ignored, without a cursor every archived page is replayed
+func (r *ReplayProvider) ExportUsers(ctx context.Context, since int64, cursor string, process func(page []models.User, next string) error) (last string, err error) {
+	var rezponze struct {
+		Payload []models.User `json:"users"`
+	}
+
+	pages, err := r.pages(ctx, "users")
+	if err == nil {
+		pages, err = resumeAfter(pages, "users", cursor)
+	}
+	if err != nil {
+		return cursor, err
+	}
+
+	last = cursor
+	for _, page := range pages {
+		rezponze.Payload = nil
+		if err = r.decode(ctx, page.key, &rezponze); err != nil {
+			return last, err
+		}
+
+		next := last
+		if page.checkpoint != "" {
+			next = page.checkpoint
+		}
+		if err = process(rezponze.Payload, next); err != nil {
+			return last, err
+		}
+		last = next
+	}
+	return last, nil
+}
+
+// ExportTickets - since is ignored, without a cursor every archived page is replayed
+func (r *ReplayProvider) ExportTickets(ctx context.Context, since int64, cursor string, process func(page models.Ticket_page, next string) error) (last string, err error) {
+	var rezponze models.Ticket_page
+
+	pages, err := r.pages(ctx, "tickets")
+	if err == nil {
+		pages, err = resumeAfter(pages, "tickets", cursor)
+	}
+	if err != nil {
+		return cursor, err
+	}
+
+	last = cursor
+	for _, page := range pages {
+		rezponze = models.Ticket_page{}
+		if err = r.decode(ctx, page.key, &rezponze); err != nil {
+			return last, err
+		}
+
+		next := last
+		if page.checkpoint != "" {
+			next = page.checkpoint
+		}
+		if err = process(rezponze, next); err != nil {
+			return last, err
+		}
+		last = next
+	}
+	return last, nil
+}
+
+// ExportTicketMetrics - metrics of single tickets are not archived, those of archived ticket pages are replayed
+// along with their tickets
+func (r *ReplayProvider) ExportTicketMetrics(ctx context.Context, tickets []int64, process func([]models.Ticket_metrics) error) (last int64, err error) {
+	if len(tickets) == 0 {
+		return 0, nil
+	}
+	return 0, fmt.Errorf("metrics of single tickets are not archived and cannot be replayed")
+}
+
+// ExportTicketAudits - pages made up of audits older than since have been replayed already, the cursor returned
+// is the one archived with the last page
+func (r *ReplayProvider) ExportTicketAudits(ctx context.Context, since int64, process func([]models.Audit) error) (last string, err error) {
+	var rezponze struct {
+		Payload []models.Audit `json:"audits"`
+	}
+
+	pages, err := r.pages(ctx, "ticket_audits")
+	if err != nil {
+		return last, err
+	}
+	for _, page := range pages {
+		rezponze.Payload = nil
+		if err = r.decode(ctx, page.key, &rezponze); err != nil {
+			return "", err
+		}
+
+		last = page.checkpoint
+		if len(rezponze.Payload) == 0 || rezponze.Payload[0].Id < since {
+			continue
+		}
+		if err = process(rezponze.Payload); err != nil {
+			return "", err
+		}
+	}
+	return last, nil
+}
@@ -0,0 +1,89 @@
+package zendesk
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+// replayed - ids and checkpoints handed to process by a ticket export
+func replayed(t *testing.T, source interface {
+	ExportTickets(context.Context, int64, string, func(models.Ticket_page, string) error) (string, error)
+}, cursor string) (ids []int64, next []string, last string, err error) {
+	last, err = source.ExportTickets(context.Background(), 0, cursor, func(page models.Ticket_page, checkpoint string) error {
+		for _, e := range page.Tickets {
+			ids = append(ids, e.Id)
+		}
+		next = append(next, checkpoint)
+		return nil
+	})
+	return ids, next, last, err
+}
+
+func TestReplayArchivedExports(t *testing.T) {
+	r := testProvider(t, map[string]string{
+		"/api/v2/incremental/tickets/cursor.json?start_time=0&include=" + ticketSideloads: `{"tickets": [{"id": 1}],
+			"after_cursor": "c1", "after_url": "{server}/api/v2/incremental/tickets/cursor.json?cursor=c1", "end_of_stream": false}`,
+		"/api/v2/incremental/tickets/cursor.json?cursor=c1": `{"tickets": [{"id": 2}], "after_cursor": "c2", "end_of_stream": true}`,
+		"/api/v2/incremental/tickets/cursor.json?cursor=c2&include=" + ticketSideloads: `{"tickets": [{"id": 3}],
+			"after_cursor": "", "end_of_stream": true}`,
+	})
+	archive := Directory(t.TempDir())
+	r.ArchiveTo(archive)
+
+	// two syncs, the second resuming where the first left off
+	for _, cursor := range []string{"", "c2"} {
+		if _, _, _, err := replayed(t, r, cursor); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replay := Replay(archive)
+	ids, next, last, err := replayed(t, replay, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 3 || ids[2] != 3 || strings.Join(next, ",") != "c1,c2,c2" || last != "c2" {
+		t.Errorf("expected every archived page in order, got %v with %v resuming from %s", ids, next, last)
+	}
+
+	forwarded := &memoryArchive{}
+	replay.ArchiveTo(forwarded)
+	if ids, _, _, err = replayed(t, replay, "c1"); err != nil || len(ids) != 2 || ids[0] != 2 {
+		t.Errorf("expected to resume after the page which led to c1, got %v %v", ids, err)
+	}
+	if len(forwarded.keys) != 2 || !strings.HasSuffix(forwarded.keys[0], "000002_c2.json.gz") {
+		t.Errorf("expected the replayed pages to be forwarded, got %v", forwarded.keys)
+	}
+	if _, _, last, err = replayed(t, replay, "unknown"); err == nil || last != "unknown" {
+		t.Errorf("expected a cursor which was never archived to fail, got %v", err)
+	}
+}
+
+func TestReplayOrganizationsSince(t *testing.T) {
+	archive := Directory(t.TempDir())
+	r := Replay(archive)
+	for _, page := range []struct {
+		key, body string
+	}{
+		{"organizations/20200301T120000.000000000Z/000001_100.json.gz", `{"organizations": [{"id": 1}]}`},
+		{"organizations/20200301T120000.000000000Z/000002_200.json.gz", `{"organizations": [{"id": 2}]}`},
+	} {
+		if err := (&ZDProvider{archive: archive}).archivePage(context.Background(), page.key, []byte(page.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []int64
+	last, err := r.ExportOrganizations(context.Background(), 100, func(page []models.Organization, next int64) error {
+		for _, e := range page {
+			ids = append(ids, e.Id)
+		}
+		return nil
+	})
+	if err != nil || len(ids) != 1 || ids[0] != 2 || last != 200 {
+		t.Errorf("expected only the page ending after 100, got %v resuming from %d: %v", ids, last, err)
+	}
+}
@@ -1,37 +1,47 @@
 package zendesk
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/rnpridgeon/zendb/models"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
-	"fmt"
-	"log"
-	"encoding/json"
+	"sync"
 	"time"
 )
 
-var (
-	httpClient *http.Client
-	header     http.Header
-)
+const base = "https://%s.zendesk.com/api/v2/"
 
-type pager struct {
-	Next     string `json:"next_page"`
-	Previous string `json:"previous_page"`
-	End      int64  `json:"end_time"`
-	Count    int64  `json:"count"`
-}
+// collections sideloaded by the incremental ticket export
+const ticketSideloads = "metric_sets,users,groups,organizations"
 
-const base = "https://%s.zendesk.com/api/v2/"
+// concurrent metrics requests when the configuration does not specify any
+const defaultWorkers = 4
 
 type ZendeskConfig struct {
 	User      string
 	Password  string
 	Subdomain string
+	RateLimit *RateLimitConfig `json:"rate_limit"`
+	Workers   int              `json:"workers"`
 }
 
+// ZDProvider - safe for concurrent use, every call builds its own requests from the immutable api root
 type ZDProvider struct {
-	*http.Request
+	base    *url.URL
+	client  *http.Client
+	header  http.Header
+	limiter *limiter
+	workers int
+	archive Archive
+}
+
+// ArchiveTo - stores the raw body of every page fetched from here on in a, see archive.go
+func (r *ZDProvider) ArchiveTo(a Archive) {
+	r.archive = a
 }
 
 func timeTrack(start time.Time, name string) {
@@ -39,243 +49,316 @@ func timeTrack(start time.Time, name string) {
 	log.Printf("INFO: %s took %s", name, elapsed)
 }
 
-func deserialize(request *http.Request, object interface{}) {
-	resp, err := httpClient.Do(request)
-
-	if err != nil || resp.StatusCode != http.StatusOK {
-		log.Printf("ERROR: Unable to fetch from fetch from %s: %s", request.URL, resp.Status)
+// deserialize - fetches path, relative to the api root, into object
+func (r *ZDProvider) deserialize(ctx context.Context, path string, object interface{}) error {
+	target, err := r.base.Parse(path)
+	if err != nil {
+		return &TransportError{path, err}
 	}
-	if err := json.NewDecoder(resp.Body).Decode(object); err != nil {
-		log.Printf("Failed to fetch from %s: \n\t%s)", request.URL, err)
+
+	body, err := r.fetch(ctx, target)
+	if err != nil {
+		return err
 	}
 
-	resp.Body.Close()
+	if err = json.Unmarshal(body, object); err != nil {
+		return &DecodeError{target.String(), err}
+	}
+	return nil
 }
 
-func (r *ZDProvider) ListTicketFields(process func([]models.Ticket_field)) (last int64) {
-	r.URL, _ = r.URL.Parse("./ticket_fields.json")
+// newRequest - a fresh authenticated GET for target
+func (r *ZDProvider) newRequest(ctx context.Context, target *url.URL) (*http.Request, error) {
+	req, err := http.NewRequest("GET", target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range r.header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	return req.WithContext(ctx), nil
+}
 
+func (r *ZDProvider) ListTicketFields(ctx context.Context, process func([]models.Ticket_field) error) (last int64, err error) {
 	var rezponze struct {
-		pager
 		Payload []models.Ticket_field `json:"ticket_fields"`
 	}
 
-	//iterate over pages, TODO: this needs to be moved out and cleaned up to keep things DRY
-	for {
-		deserialize(r.Request, &rezponze)
-
-		process(rezponze.Payload)
+	pages := r.Paginate(ctx, "./ticket_fields.json", OFFSET)
+	for pages.Next() {
+		rezponze.Payload = nil
+		if err = pages.Decode(&rezponze); err != nil {
+			return last, err
+		}
 
-		if rezponze.Next != "" {
-			r.URL, _ = r.URL.Parse(rezponze.Next)
-			rezponze.Next = ""
-			continue
+		if err = process(rezponze.Payload); err != nil {
+			return last, err
+		}
+		for _, e := range rezponze.Payload {
+			if e.Id > last {
+				last = e.Id
+			}
 		}
-		break
 	}
-
-	// clean-up
-	r.URL, _ = r.URL.Parse("./")
-	return rezponze.Payload[len(rezponze.Payload)-1].Id
+	return last, pages.Err()
 }
 
-func (r *ZDProvider) ExportTicketMetrics(tickets []int64, process func([]models.Ticket_metrics)) (last int64) {
-	if len(tickets) == 0 { return 0 }
+// ExportTicketMetrics - fetches the metrics of specific tickets with a bounded pool of workers which all
+// wait their turn with the rate limiter, incremental syncs receive metrics sideloaded by ExportTickets instead. Deleted tickets are skipped, last is the number of metrics exported.
+func (r *ZDProvider) ExportTicketMetrics(ctx context.Context, tickets []int64, process func([]models.Ticket_metrics) error) (last int64, err error) {
+	if len(tickets) == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
+	// results are indexed by position so metrics are processed in the order tickets were given
 	payload := make([]models.Ticket_metrics, len(tickets))
+	jobs := make(chan int)
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+	)
+	for w := 0; w < r.workers && w < len(tickets); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				var rezponze struct {
+					Payload models.Ticket_metrics `json:"ticket_metric"`
+				}
+
+				fetchErr := r.deserialize(ctx, fmt.Sprintf("./tickets/%d/metrics.json", tickets[i]), &rezponze)
+				if statusErr, ok := fetchErr.(*StatusError); ok && statusErr.StatusCode == http.StatusNotFound {
+					continue
+				}
+				if fetchErr != nil {
+					// the first failure wins, cancelling the remaining requests
+					once.Do(func() {
+						err = fetchErr
+						cancel()
+					})
+					continue
+				}
+				payload[i] = rezponze.Payload
+			}
+		}()
+	}
 
-	var rezponze struct {
-		Payload models.Ticket_metrics `json:"ticket_metric"`
+feed:
+	for i := range tickets {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
 	}
+	close(jobs)
+	wg.Wait()
 
-	var index int64 = 0
-	for ticket := range tickets {
-		r.URL, _ = r.URL.Parse(fmt.Sprintf("./tickets/%d/metrics.json", ticket))
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return 0, err
+	}
 
-		deserialize(r.Request, &rezponze)
-		if rezponze.Payload.Ticket_id > 0 {
-			payload[index] = rezponze.Payload
-			index++
+	metrics := payload[:0]
+	for _, e := range payload {
+		if e.Ticket_id > 0 {
+			metrics = append(metrics, e)
 		}
-		r.URL, _ = r.URL.Parse("../../")
 	}
 
-	process(payload[:index])
-	return index
+	return int64(len(metrics)), process(metrics)
 }
 
-func (r *ZDProvider) ListGroups(process func([]models.Group)) (last int64) {
-	r.URL, _ = r.URL.Parse("./groups.json")
-
+func (r *ZDProvider) ListGroups(ctx context.Context, process func([]models.Group) error) (last int64, err error) {
 	var rezponze struct {
-		pager
 		Payload []models.Group `json:"groups"`
 	}
 
-	//iterate over pages, TODO: this needs to be moved out and cleaned up to keep things DRY
-	for {
-		deserialize(r.Request, &rezponze)
+	pages := r.Paginate(ctx, "./groups.json", OFFSET)
+	for pages.Next() {
+		rezponze.Payload = nil
+		if err = pages.Decode(&rezponze); err != nil {
+			return last, err
+		}
 
-		process(rezponze.Payload)
-		if rezponze.Next != "" {
-			r.URL, _ = r.URL.Parse(rezponze.Next)
-			rezponze.Next = ""
-			continue
+		if err = process(rezponze.Payload); err != nil {
+			return last, err
+		}
+		for _, e := range rezponze.Payload {
+			if e.Id > last {
+				last = e.Id
+			}
 		}
-		break
 	}
-	// clean-up
-	r.URL, _ = r.URL.Parse("./")
-	return rezponze.Payload[len(rezponze.Payload)-1].Id
+	return last, pages.Err()
 }
 
-func (r *ZDProvider) ExportOrganizations(since int64, process func([]models.Organization)) (last int64) {
-	r.URL, _ = r.URL.Parse(fmt.Sprintf("./incremental/organizations.json?start_time=%s",
-		strconv.FormatInt(since, 10)))
-
+// ExportOrganizations - zendesk offers no cursor based export for organizations, this remains time based
+func (r *ZDProvider) ExportOrganizations(ctx context.Context, since int64, process func(page []models.Organization, next int64) error) (last int64, err error) {
 	var rezponze struct {
-		pager
 		Payload []models.Organization `json:"organizations"`
 	}
 
-	//iterate over pages, TODO: this needs to be moved out and cleaned up to keep things DRY
-	for {
-		deserialize(r.Request, &rezponze)
+	last = since
+	pages := r.Paginate(ctx, fmt.Sprintf("./incremental/organizations.json?start_time=%d", since), INCREMENTAL)
+	for pages.Next() {
+		rezponze.Payload = nil
+		if err = pages.Decode(&rezponze); err != nil {
+			return last, err
+		}
 
-		process(rezponze.Payload)
-		if rezponze.Count >= 1000 {
-			r.URL, _ = r.URL.Parse(rezponze.Next)
-			rezponze.Next = ""
-			continue
+		next, _ := strconv.ParseInt(pages.Cursor(), 10, 64)
+		if err = process(rezponze.Payload, next); err != nil {
+			return last, err
 		}
-		break
+		last = next
 	}
-	// clean-up
-	r.URL, _ = r.URL.Parse("../")
-	return rezponze.End
+	return last, pages.Err()
 }
 
-func (r *ZDProvider) GetOrganization(id int64, process func(organization models.Organization)) {
-	r.URL, _ = r.URL.Parse(fmt.Sprintf("./organizationss/%s.json",strconv.FormatInt(id, 10)))
+func (r *ZDProvider) GetOrganization(ctx context.Context, id int64, process func(organization models.Organization) error) error {
+	var rezponze struct {
+		Payload models.Organization `json:"organization"`
+	}
 
-	var payload models.Organization
-	deserialize(r.Request, &payload)
-	process(payload)
+	err := r.deserialize(ctx, fmt.Sprintf("./organizations/%d.json", id), &rezponze)
+	if err != nil {
+		return err
+	}
 
-	r.URL, _ = r.URL.Parse("../")
+	return process(rezponze.Payload)
 }
 
-func (r *ZDProvider) ExportUsers(since int64, process func([]models.User)) (last int64) {
-	r.URL, _ = r.URL.Parse(fmt.Sprintf("./incremental/users.json?start_time=%s",
-		strconv.FormatInt(since, 10)))
-
+// ExportUsers - cursor based incremental export, starting from cursor or from since when there is none yet
+func (r *ZDProvider) ExportUsers(ctx context.Context, since int64, cursor string, process func(page []models.User, next string) error) (last string, err error) {
 	var rezponze struct {
-		pager
 		Payload []models.User `json:"users"`
 	}
 
-	//iterate over pages, TODO: this needs to be moved out to keep things DRY
-	for {
-		deserialize(r.Request, &rezponze)
+	last = cursor
+	pages := r.Paginate(ctx, "./incremental/users/cursor.json?"+startQuery(since, cursor), INCREMENTAL_CURSOR)
+	for pages.Next() {
+		rezponze.Payload = nil
+		if err = pages.Decode(&rezponze); err != nil {
+			return last, err
+		}
 
-		process(rezponze.Payload)
-		if rezponze.Count >= 1000 {
-			r.URL, _ = r.URL.Parse(rezponze.Next)
-			rezponze.Next = ""
-			continue
+		next := resume(pages, cursor)
+		if err = process(rezponze.Payload, next); err != nil {
+			return last, err
 		}
-		break
+		last = next
 	}
-
-	// clean-up
-	r.URL, _ = r.URL.Parse("../")
-	return rezponze.End
+	return last, pages.Err()
 }
 
-func (r *ZDProvider) ExportTickets(since int64, process func([]models.Ticket)) (last int64) {
-	r.URL, _ = r.URL.Parse(fmt.Sprintf("./incremental/tickets.json?start_time=%d", since))
-
-	var rezponze struct {
-		pager
-		Payload []models.Ticket `json:"tickets"`
-	}
-
-	//iterate over pages, TODO: this needs to be moved out to keep things DRY
-	for {
-		deserialize(r.Request, &rezponze)
+// ExportTickets - cursor based incremental export, starting from cursor or from since when there is none yet.
+// Each page sideloads the metrics, users, groups and organizations its tickets refer to.
+func (r *ZDProvider) ExportTickets(ctx context.Context, since int64, cursor string, process func(page models.Ticket_page, next string) error) (last string, err error) {
+	var rezponze models.Ticket_page
+
+	last = cursor
+	pages := r.Paginate(ctx, "./incremental/tickets/cursor.json?"+startQuery(since, cursor)+"&include="+ticketSideloads, INCREMENTAL_CURSOR)
+	for pages.Next() {
+		rezponze = models.Ticket_page{}
+		if err = pages.Decode(&rezponze); err != nil {
+			return last, err
+		}
 
-		process(rezponze.Payload)
-		if rezponze.Count >= 1000 {
-			r.URL, _ = r.URL.Parse(rezponze.Next)
-			rezponze.Next = ""
-			continue
+		next := resume(pages, cursor)
+		if err = process(rezponze, next); err != nil {
+			return last, err
 		}
-		break
+		last = next
 	}
+	return last, pages.Err()
+}
 
-	// clean-up
-	r.URL, _ = r.URL.Parse("../")
-	return rezponze.End
+// startQuery - query starting a cursor based export, from cursor when resuming and from since otherwise
+func startQuery(since int64, cursor string) string {
+	if cursor != "" {
+		return "cursor=" + url.QueryEscape(cursor)
+	}
+	return fmt.Sprintf("start_time=%d", since)
 }
 
-func (r *ZDProvider) ExportTicketAudits(since int64, process func([]models.Audit)) (last string) {
-	r.URL, _ = r.URL.Parse("./ticket_audits.json?cursor=")
+// resume - the cursor a subsequent export resumes from, unchanged when no page reported one
+func resume(pages *Pages, cursor string) string {
+	if pages.Cursor() != "" {
+		return pages.Cursor()
+	}
+	return cursor
+}
 
+func (r *ZDProvider) ExportTicketAudits(ctx context.Context, since int64, process func([]models.Audit) error) (last string, err error) {
 	var rezponze struct {
-		Before_URL			string			`json:"before_url"`
-		Before_Cursor		string			`json:"before_cursor"`
-		After_URL			string		 	`json:"after_url"`
-		After_Cursor		string			`json:"after_cursor"`
-		Payload 			[]models.Audit	`json:"audits"`
+		Payload []models.Audit `json:"audits"`
 	}
 
-	for {
-		deserialize(r.Request, &rezponze)
-
-		process(rezponze.Payload)
-		if rezponze.Before_Cursor == "" || rezponze.Payload[0].Id < since {
-			break;
+	// audits are listed newest first, walk backwards until we reach audits we have already seen
+	pages := r.Paginate(ctx, "./ticket_audits.json?cursor=", CURSOR)
+	for pages.Next() {
+		rezponze.Payload = nil
+		if err = pages.Decode(&rezponze); err != nil {
+			return last, err
 		}
 
-		r.URL, _ = r.URL.Parse(rezponze.Before_URL)
-		rezponze.Before_Cursor = ""
+		if err = process(rezponze.Payload); err != nil {
+			return "", err
+		}
+		if len(rezponze.Payload) == 0 || rezponze.Payload[0].Id < since {
+			break
+		}
 	}
 
-	// clean-up
-	r.URL, _ = r.URL.Parse("./")
-	return rezponze.After_Cursor
+	return pages.Cursor(), pages.Err()
 }
 
-func (r *ZDProvider) GetTicket(id int64, process func(ticket models.Ticket)) {
-	r.URL, _ = r.URL.Parse(fmt.Sprintf("./tickets/%d.json",id))
+func (r *ZDProvider) GetTicket(ctx context.Context, id int64, process func(ticket models.Ticket) error) error {
+	var rezponze struct {
+		Payload models.Ticket `json:"ticket"`
+	}
 
-	var payload models.Ticket
-	deserialize(r.Request, &payload)
-	process(payload)
+	err := r.deserialize(ctx, fmt.Sprintf("./tickets/%d.json", id), &rezponze)
+	if err != nil {
+		return err
+	}
 
-	r.URL, _ = r.URL.Parse("../")
+	return process(rezponze.Payload)
 }
 
-func newHandler(conf *ZendeskConfig) (handle *ZDProvider) {
-	req, _ := http.NewRequest("GET", fmt.Sprintf(base, conf.Subdomain), nil)
-	handle = &ZDProvider{req}
-	handle.Header = header
-	handle.SetBasicAuth(conf.User, conf.Password)
+func newHandler(client *http.Client, conf *ZendeskConfig) (handle *ZDProvider) {
+	root, _ := url.Parse(fmt.Sprintf(base, conf.Subdomain))
 
-	return handle
-}
-
-func Open(client *http.Client, conf *ZendeskConfig) (handle *ZDProvider) {
-	httpClient = client
-	return newHandler(conf)
-}
+	// borrow a request to encode credentials, every request copies these headers
+	req, _ := http.NewRequest("GET", root.String(), nil)
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept-Charset", "utf-8")
+	req.Header.Add("Accept-Language", "en-US")
+	req.SetBasicAuth(conf.User, conf.Password)
 
-func init() {
-	header = make(http.Header)
+	workers := conf.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
 
-	header.Add("Accept", "application/json")
-	header.Add("Content-Type", "application/json")
-	header.Add("Accept-Charset", "utf-8")
-	header.Add("Accept-Language", "en-US")
+	return &ZDProvider{
+		base:    root,
+		client:  client,
+		header:  req.Header,
+		limiter: newLimiter(conf.RateLimit),
+		workers: workers,
+	}
+}
 
+func Open(client *http.Client, conf *ZendeskConfig) (handle *ZDProvider) {
+	return newHandler(client, conf)
 }
@@ -1,6 +1,7 @@
 package zendb
 
 import (
+	"context"
 	"time"
 	"log"
 )
@@ -16,7 +17,7 @@ const (
 
 type Scheduler struct {
 	*time.Ticker
-	run func()()
+	run func(context.Context)
 	done chan bool
 }
 
@@ -25,28 +26,37 @@ func TimeTrack(start time.Time, name string) {
 	log.Printf("INFO: %s took %s", name, elapsed)
 }
 
-func NewScheduler(tickTime time.Duration, run func()()) (Scheduler){
-	return Scheduler{time.NewTicker(tickTime), run, make(chan bool)}
+func NewScheduler(tickTime time.Duration, run func(context.Context)) (Scheduler){
+	return Scheduler{time.NewTicker(tickTime), run, make(chan bool, 1)}
 }
 
-func (s *Scheduler) Start() {
+// Start - runs the task until Stop is called or ctx is cancelled, ctx is handed to every run
+func (s *Scheduler) Start(ctx context.Context) {
 	log.Print("INFO: Starting scheduler...")
+	defer s.Ticker.Stop()
+
 	//Execute task immediately, schedule subsequent runs
-	s.run()
+	s.run(ctx)
 	for {
 		select {
 			case <-s.Ticker.C:
 				log.Print("INFO: Executing task")
-				s.run()
+				s.run(ctx)
+			case <-ctx.Done():
+				log.Print("INFO: Stopping scheduler, context cancelled")
+				return
 			case <-s.done:
-				s.Ticker.Stop()
 				log.Print("INFO: Stopping scheduler")
 				return
 		}
 	}
 }
 
+// Stop - stops the scheduler once the current run, if any, completes
 func (s *Scheduler) Stop() {
-	s.done <- true
+	select {
+	case s.done <- true:
+	default:
+	}
 }
 
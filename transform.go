@@ -0,0 +1,159 @@
+package zendb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rnpridgeon/zendb/models"
+)
+
+// Post processing queries, flatten custom field values and metrics onto tickets
+const (
+	// mysql
+	enrichPriority = `
+		UPDATE tickets
+			JOIN ticket_metadata on tickets.id = ticket_metadata.ticket_id
+			JOIN ticket_fields on field_id = ticket_fields.id
+		SET tickets.priority = ticket_metadata.transformed_value
+		WHERE ticket_fields.title = "Case Priority"`
+
+	enrichComponent = `
+		UPDATE tickets
+			JOIN ticket_metadata on tickets.id = ticket_metadata.ticket_id
+			JOIN ticket_fields on field_id = ticket_fields.id
+		SET tickets.component = ticket_metadata.transformed_value
+		WHERE ticket_fields.title = "Component"`
+
+	enrichVersion = `
+		UPDATE tickets
+			JOIN ticket_metadata on tickets.id = ticket_metadata.ticket_id
+			JOIN ticket_fields on field_id = ticket_fields.id
+		SET tickets.version = ticket_metadata.raw_value
+		WHERE ticket_fields.title like "%Kafka Version"`
+
+	enrichTTFR = `
+		UPDATE tickets
+			JOIN ticket_metrics on tickets.id = ticket_metrics.ticket_id
+		SET tickets.ttfr = ticket_metrics.ttfr`
+
+	enrichSolved = `
+		UPDATE tickets
+			JOIN ticket_metrics on tickets.id = ticket_metrics.ticket_id
+		SET tickets.solved_at = ticket_metrics.solved_at`
+
+	// postgres, custom field values are jsonb and unset times are NULL
+	pgEnrichPriority = `
+		UPDATE tickets SET priority = ticket_metadata.transformed_value
+		FROM ticket_metadata JOIN ticket_fields ON field_id = ticket_fields.id
+		WHERE tickets.id = ticket_metadata.ticket_id AND ticket_fields.title = 'Case Priority'`
+
+	pgEnrichComponent = `
+		UPDATE tickets SET component = ticket_metadata.transformed_value
+		FROM ticket_metadata JOIN ticket_fields ON field_id = ticket_fields.id
+		WHERE tickets.id = ticket_metadata.ticket_id AND ticket_fields.title = 'Component'`
+
+	pgEnrichVersion = `
+		UPDATE tickets SET version = ticket_metadata.raw_value #>> '{}'
+		FROM ticket_metadata JOIN ticket_fields ON field_id = ticket_fields.id
+		WHERE tickets.id = ticket_metadata.ticket_id AND ticket_fields.title LIKE '%Kafka Version'`
+
+	pgEnrichTTFR = `
+		UPDATE tickets SET ttfr = ticket_metrics.ttfr
+		FROM ticket_metrics WHERE tickets.id = ticket_metrics.ticket_id`
+
+	pgEnrichSolved = `
+		UPDATE tickets SET solved_at = ticket_metrics.solved_at
+		FROM ticket_metrics WHERE tickets.id = ticket_metrics.ticket_id`
+
+	// sqlite shares the postgres UPDATE ... FROM form, custom field values are JSON text
+	sqliteEnrichVersion = `
+		UPDATE tickets SET version = json_extract(ticket_metadata.raw_value, '$')
+		FROM ticket_metadata JOIN ticket_fields ON field_id = ticket_fields.id
+		WHERE tickets.id = ticket_metadata.ticket_id AND ticket_fields.title LIKE '%Kafka Version'`
+)
+
+// enrichments - post processing queries by the dialect of the sink running them
+var enrichments = map[string][]string{
+	MYSQL:    {enrichPriority, enrichComponent, enrichVersion, enrichSolved, enrichTTFR},
+	POSTGRES: {pgEnrichPriority, pgEnrichComponent, pgEnrichVersion, pgEnrichSolved, pgEnrichTTFR},
+	SQLITE:   {pgEnrichPriority, pgEnrichComponent, sqliteEnrichVersion, pgEnrichSolved, pgEnrichTTFR},
+}
+
+// ComponentTransformation - normalizes the component custom field identified by fieldID
+func ComponentTransformation(fieldID int64) func(interface{}) {
+	return func(obj interface{}) {
+		entity := obj.(*models.Custom_fields)
+		if entity.Id != fieldID || entity.Value == nil {
+			return
+		}
+
+		val := entity.Value.(string)
+		switch {
+		case strings.Contains(val, "c3") || strings.Contains(val, "confluent_control_center"):
+			entity.Transformed = "c3"
+		case strings.Contains(val, "broker"):
+			entity.Transformed = "broker"
+		case strings.Contains(val, "auto_data_balancer"):
+			entity.Transformed = "adb"
+		case strings.Contains(val, "_jms_"):
+			entity.Transformed = "clients-jms"
+		case strings.Contains(val, "python_"):
+			entity.Transformed = "clients-python"
+		case strings.Contains(val, "client_net"):
+			entity.Transformed = "clients-dotNET"
+		case strings.Contains(val, "_c_"):
+			entity.Transformed = "clients-c/c++"
+		case strings.Contains(val, "_go_"):
+			entity.Transformed = "clients-golang"
+		case strings.Contains(val, "third-party"):
+			entity.Transformed = "clients-third-party"
+		case strings.Contains(val, "java_"):
+			entity.Transformed = "clients-java"
+		default:
+			entity.Transformed = val
+		}
+	}
+}
+
+// PriorityTransformation - truncates the priority custom field identified by fieldID to its level, e.g. P1
+func PriorityTransformation(fieldID int64) func(interface{}) {
+	return func(obj interface{}) {
+		entity := obj.(*models.Custom_fields)
+		if entity.Id != fieldID || entity.Value == nil {
+			return
+		}
+
+		if val := entity.Value.(string); len(val) >= 2 {
+			entity.Transformed = val[:2]
+		}
+	}
+}
+
+// EnrichTickets - post processing step copying transformed custom fields and metrics onto tickets
+func EnrichTickets(ctx context.Context, sink Sink) error {
+	defer TimeTrack(time.Now(), "Ticket post processing")
+
+	db, ok := sink.(Execer)
+	if !ok {
+		return fmt.Errorf("ticket enrichment requires raw query support, %T provides none", sink)
+	}
+
+	dialect := MYSQL
+	if d, ok := sink.(Dialect); ok {
+		dialect = d.Dialect()
+	}
+
+	queries, ok := enrichments[dialect]
+	if !ok {
+		return fmt.Errorf("ticket enrichment does not support the %s dialect", dialect)
+	}
+
+	for _, qry := range queries {
+		if _, err := db.ExecRaw(ctx, qry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
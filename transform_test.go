@@ -0,0 +1,50 @@
+package zendb
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type fakeExecer struct {
+	fakeSink
+	dialect string
+	queries []string
+}
+
+func (s *fakeExecer) ExecRaw(ctx context.Context, qry string) (int64, error) {
+	s.queries = append(s.queries, qry)
+	return 0, nil
+}
+
+type fakeDialect struct {
+	fakeExecer
+}
+
+func (s *fakeDialect) Dialect() string {
+	return s.dialect
+}
+
+func TestEnrichTicketsFollowsSinkDialect(t *testing.T) {
+	mysql := &fakeExecer{}
+	if err := EnrichTickets(context.Background(), mysql); err != nil {
+		t.Fatal(err)
+	}
+	if len(mysql.queries) == 0 || !strings.Contains(mysql.queries[0], "JOIN ticket_metadata") {
+		t.Errorf("expected mysql queries by default, got %q", mysql.queries)
+	}
+
+	postgres := &fakeDialect{fakeExecer{dialect: POSTGRES}}
+	if err := EnrichTickets(context.Background(), postgres); err != nil {
+		t.Fatal(err)
+	}
+	for _, qry := range postgres.queries {
+		if !strings.Contains(qry, "FROM ticket_") {
+			t.Errorf("expected postgres UPDATE ... FROM, got %s", qry)
+		}
+	}
+
+	if err := EnrichTickets(context.Background(), &fakeDialect{fakeExecer{dialect: "oracle"}}); err == nil {
+		t.Error("expected an unknown dialect to fail")
+	}
+}
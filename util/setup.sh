@@ -24,4 +24,6 @@ cd ..
 
 ./util/initdb.sh -s mysql
 
-go test
+go run ./cmd/zendb -config ./exclude/conf.json migrate
+
+go run ./cmd/zendb -config ./exclude/conf.json sync --once